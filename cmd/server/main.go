@@ -2,9 +2,13 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"math/big"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -12,8 +16,10 @@ import (
 	"whitelist-token-backend/internal/database"
 	"whitelist-token-backend/internal/handlers"
 	"whitelist-token-backend/internal/middleware"
+	"whitelist-token-backend/internal/models"
 	"whitelist-token-backend/internal/services"
 
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
@@ -43,6 +49,10 @@ func main() {
 		logger.Fatalf("Failed to run auto-migrations: %v", err)
 	}
 
+	if err := database.SeedRoles(db); err != nil {
+		logger.Fatalf("Failed to seed roles: %v", err)
+	}
+
 	// Initialize Redis
 	redisClient, err := database.InitializeRedis(cfg.RedisURL)
 	if err != nil {
@@ -59,23 +69,139 @@ func main() {
 	if err := blockchainService.SetPrivateKey(cfg.PrivateKey); err != nil {
 		logger.Fatalf("Failed to set private key: %v", err)
 	}
+	blockchainService.SetMulticallAddress(cfg.MulticallAddress)
+	if err := blockchainService.LoadArtifacts(cfg.SaleArtifactPath, cfg.TokenArtifactPath); err != nil {
+		logger.Fatalf("Failed to load contract ABIs: %v", err)
+	}
+	missing, err := blockchainService.CheckDeployedContracts(context.Background())
+	if err != nil {
+		logger.Warnf("Failed to check deployed contracts: %v", err)
+	} else if len(missing) > 0 {
+		logger.Warnf("Deployed contracts do not appear to implement %s; calls to them will fail", strings.Join(missing, ", "))
+	}
+	// Subscriptions are optional; without them the indexer polls
+	if err := blockchainService.SetWebsocketURL(cfg.BlockchainWSURL); err != nil {
+		logger.Warnf("Websocket RPC unavailable: %v", err)
+	}
+
+	chainID, err := blockchainService.ChainID(context.Background())
+	if err != nil {
+		logger.Fatalf("Failed to get chain ID: %v", err)
+	}
+
+	// Every write goes through the transaction manager, which owns the signer's nonces
+	maxTxCost, err := etherToWei(cfg.MaxTxCostEth)
+	if err != nil {
+		logger.Fatalf("Invalid MAX_TX_COST_ETH: %v", err)
+	}
+	txManager := services.NewTxManager(db, redisClient, blockchainService, services.TxManagerConfig{
+		PollInterval:    time.Duration(cfg.TxPollSec) * time.Second,
+		MaxFeeCap:       gweiToWei(cfg.MaxFeeGwei),
+		MaxTipCap:       gweiToWei(cfg.MaxPriorityFeeGwei),
+		MaxTxCost:       maxTxCost,
+		GasMultiplier:   cfg.GasLimitMultiplier,
+		BumpAfterBlocks: uint64(cfg.TxBumpBlocks),
+		BumpPercent:     int64(cfg.TxBumpPercent),
+		Confirmations:   uint64(cfg.Confirmations),
+	}, logger)
+	blockchainService.SetTxManager(txManager)
 
 	// Initialize services
 	whitelistService := services.NewWhitelistService(db, redisClient, blockchainService, logger)
-	authService := services.NewAuthService(cfg.JWTSecret, logger)
+	if err := whitelistService.SetMode(cfg.WhitelistMode); err != nil {
+		logger.Fatalf("Invalid WHITELIST_MODE: %v", err)
+	}
+	// Whitelist entries follow the transaction sent for them
+	txManager.OnComplete(whitelistService.TransactionComplete)
+	rbacService := services.NewRBACService(db, redisClient, logger)
+	if err := rbacService.EnsureSuperAdmins(context.Background(), cfg.AdminAddresses); err != nil {
+		logger.Fatalf("Failed to bootstrap admin roles: %v", err)
+	}
+	authService, err := services.NewAuthService(db, redisClient, rbacService, blockchainService, cfg.JWTSecret, services.AuthConfig{
+		Domain:          cfg.SIWEDomain,
+		URI:             cfg.SIWEURI,
+		Statement:       cfg.SIWEStatement,
+		ChainID:         chainID.Int64(),
+		NonceTTL:        time.Duration(cfg.SIWENonceTTLMin) * time.Minute,
+		NonceRateLimit:  cfg.NonceRateLimitPerMin,
+		SigningMethod:   cfg.JWTSigningMethod,
+		PrivateKeyPath:  cfg.JWTPrivateKeyPath,
+		PublicKeyPath:   cfg.JWTPublicKeyPath,
+		Issuer:          cfg.JWTIssuer,
+		Audience:        cfg.JWTAudience,
+		AccessTokenTTL:  time.Duration(cfg.JWTAccessTTLMin) * time.Minute,
+		RefreshTokenTTL: time.Duration(cfg.RefreshTokenTTLHrs) * time.Hour,
+	}, logger)
+	if err != nil {
+		logger.Fatalf("Failed to initialize auth service: %v", err)
+	}
+	apiKeyService := services.NewAPIKeyService(db, rbacService, logger)
+	reconcilerService := services.NewReconcilerService(db, redisClient, blockchainService, services.ReconcilerConfig{
+		Interval:   time.Duration(cfg.ReconcileIntervalMin) * time.Minute,
+		BatchSize:  cfg.ReconcileBatchSize,
+		AutoRepair: cfg.ReconcileAutoRepair,
+	}, logger)
+	batchService := services.NewBatchService(db, redisClient, blockchainService, whitelistService, services.BatchConfig{
+		ChunkSize:     cfg.BatchChunkSize,
+		MaxOperations: cfg.BatchMaxOperations,
+	}, logger)
+	schedulerService := services.NewSchedulerService(db, redisClient, whitelistService, batchService,
+		time.Duration(cfg.ScheduleIntervalSec)*time.Second, logger)
+	applicationService := services.NewApplicationService(db, redisClient, authService, whitelistService, services.ApplicationConfig{
+		Domain:   cfg.SIWEDomain,
+		NonceTTL: time.Duration(cfg.SIWENonceTTLMin) * time.Minute,
+	}, logger)
+	importService := services.NewImportService(db, whitelistService, batchService, logger)
+	exportService := services.NewExportService(db, whitelistService, logger)
+	indexerService := services.NewIndexerService(db, redisClient, blockchainService, whitelistService, services.IndexerConfig{
+		DeployBlock:   uint64(cfg.ContractDeployBlock),
+		PollInterval:  time.Duration(cfg.IndexerPollSec) * time.Second,
+		MaxRange:      uint64(cfg.IndexerMaxRange),
+		Confirmations: uint64(cfg.Confirmations),
+	}, logger)
 	analyticsService := services.NewAnalyticsService(db, redisClient, logger)
 
 	// Initialize handlers
 	handlers := handlers.NewHandlers(
 		whitelistService,
 		authService,
+		rbacService,
+		reconcilerService,
+		batchService,
+		schedulerService,
+		applicationService,
+		importService,
+		exportService,
+		apiKeyService,
 		analyticsService,
 		blockchainService,
 		logger,
 	)
 
+	// Background jobs run until shutdown, which waits for their current step
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	var background sync.WaitGroup
+	runBackground := func(start func(context.Context)) {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			start(backgroundCtx)
+		}()
+	}
+	// The merkle whitelist lives off-chain, so there is no mapping to reconcile
+	if whitelistService.Mode() == services.WhitelistModeMapping {
+		runBackground(reconcilerService.Start)
+	}
+	if cfg.ContractAddress != "" || cfg.TokenAddress != "" {
+		runBackground(indexerService.Start)
+	}
+	runBackground(txManager.Start)
+	runBackground(batchService.Start)
+	runBackground(schedulerService.Start)
+
 	// Setup router
-	router := setupRouter(cfg, handlers, logger)
+	router := setupRouter(cfg, handlers, authService, rbacService, apiKeyService, logger)
 
 	// Setup server
 	server := &http.Server{
@@ -100,6 +226,7 @@ func main() {
 	<-quit
 
 	logger.Info("Shutting down server...")
+	stopBackground()
 
 	// Give outstanding requests 30 seconds to complete
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -109,10 +236,21 @@ func main() {
 		logger.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	stopped := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		logger.Warn("Background jobs did not stop in time")
+	}
+
 	logger.Info("Server exited")
 }
 
-func setupRouter(cfg *config.Config, h *handlers.Handlers, logger *logrus.Logger) *gin.Engine {
+func setupRouter(cfg *config.Config, h *handlers.Handlers, authService *services.AuthService, rbacService *services.RBACService, apiKeyService *services.APIKeyService, logger *logrus.Logger) *gin.Engine {
 	router := gin.New()
 
 	// Middleware
@@ -131,8 +269,11 @@ func setupRouter(cfg *config.Config, h *handlers.Handlers, logger *logrus.Logger
 		// Public routes
 		auth := v1.Group("/auth")
 		{
+			auth.POST("/nonce", h.GetNonce)
 			auth.POST("/login", h.Login)
 			auth.POST("/verify", h.VerifySignature)
+			auth.POST("/refresh", h.RefreshToken)
+			auth.POST("/logout", h.Logout)
 		}
 
 		// Whitelist routes
@@ -140,6 +281,9 @@ func setupRouter(cfg *config.Config, h *handlers.Handlers, logger *logrus.Logger
 		{
 			whitelist.GET("/status/:address", h.GetWhitelistStatus)
 			whitelist.GET("/verify/:address", h.VerifyWhitelist)
+			whitelist.GET("/proof/:address", h.GetMerkleProof)
+			whitelist.POST("/apply/message", h.PrepareApplication)
+			whitelist.POST("/apply", h.SubmitApplication)
 		}
 
 		// Sale routes
@@ -158,20 +302,81 @@ func setupRouter(cfg *config.Config, h *handlers.Handlers, logger *logrus.Logger
 			analytics.GET("/users", h.GetUserAnalytics)
 		}
 
-		// Protected admin routes
+		// Protected admin routes, each gated by a database-backed permission.
+		// Callers authenticate with either a wallet session or an API key.
 		admin := v1.Group("/admin")
-		admin.Use(middleware.AuthRequired(cfg.JWTSecret))
-		admin.Use(middleware.AdminRequired())
+		admin.Use(middleware.APIKeyAuth(apiKeyService, logger))
+		admin.Use(middleware.AuthRequired(authService))
 		{
-			admin.POST("/whitelist", h.AddToWhitelist)
-			admin.DELETE("/whitelist", h.RemoveFromWhitelist)
-			admin.POST("/whitelist/batch", h.BatchUpdateWhitelist)
-			admin.GET("/users", h.GetAllUsers)
-			admin.PUT("/sale/config", h.UpdateSaleConfig)
-			admin.POST("/sale/pause", h.PauseSale)
-			admin.POST("/sale/unpause", h.UnpauseSale)
+			can := func(permission string) gin.HandlerFunc {
+				return middleware.RequirePermission(rbacService, permission)
+			}
+
+			admin.GET("/whitelist", can(models.PermWhitelistRead), h.ListWhitelistEntries)
+			admin.GET("/whitelist/merkle", can(models.PermWhitelistRead), h.ListMerkleRoots)
+			admin.POST("/whitelist/merkle", can(models.PermWhitelistWrite), h.GenerateMerkleRoot)
+			admin.GET("/whitelist/drift", can(models.PermWhitelistRead), h.GetWhitelistDrift)
+			admin.POST("/whitelist/drift/scan", can(models.PermWhitelistWrite), h.ReconcileWhitelist)
+			admin.GET("/whitelist/:address", can(models.PermWhitelistRead), h.GetWhitelistEntry)
+			admin.POST("/whitelist", can(models.PermWhitelistWrite), h.AddToWhitelist)
+			admin.DELETE("/whitelist", can(models.PermWhitelistWrite), h.RemoveFromWhitelist)
+			admin.POST("/whitelist/batch", can(models.PermWhitelistWrite), h.BatchUpdateWhitelist)
+			admin.GET("/whitelist/batch", can(models.PermWhitelistRead), h.ListBatchJobs)
+			admin.GET("/whitelist/batch/:id", can(models.PermWhitelistRead), h.GetBatchJob)
+			admin.POST("/whitelist/import", can(models.PermWhitelistWrite), h.ImportWhitelist)
+			admin.GET("/whitelist/export", can(models.PermWhitelistRead), h.ExportWhitelist)
+			admin.PUT("/whitelist/:address/tier", can(models.PermWhitelistWrite), h.AssignTier)
+			admin.POST("/whitelist/schedule", can(models.PermWhitelistWrite), h.ScheduleWhitelist)
+			admin.GET("/whitelist/applications", can(models.PermWhitelistRead), h.ListApplications)
+			admin.POST("/whitelist/applications/:id/approve", can(models.PermWhitelistWrite), h.ApproveApplication)
+			admin.POST("/whitelist/applications/:id/reject", can(models.PermWhitelistWrite), h.RejectApplication)
+			admin.GET("/whitelist/schedule", can(models.PermWhitelistRead), h.ListScheduledChanges)
+			admin.DELETE("/whitelist/schedule/:id", can(models.PermWhitelistWrite), h.CancelScheduledChange)
+			admin.POST("/whitelist/allocations/sync", can(models.PermWhitelistWrite), h.SyncUsedAllocations)
+			admin.GET("/tiers", can(models.PermWhitelistRead), h.ListTiers)
+			admin.PUT("/tiers/:name", can(models.PermWhitelistWrite), h.SaveTier)
+			admin.DELETE("/tiers/:name", can(models.PermWhitelistWrite), h.DeleteTier)
+			admin.GET("/users", can(models.PermUsersRead), h.GetAllUsers)
+			admin.POST("/users/:address/revoke-sessions", can(models.PermUsersManage), h.RevokeUserSessions)
+			admin.GET("/users/:address/roles", can(models.PermUsersRead), h.GetUserRoles)
+			admin.POST("/users/:address/roles", can(models.PermRolesManage), h.GrantRole)
+			admin.DELETE("/users/:address/roles/:role", can(models.PermRolesManage), h.RevokeRole)
+			admin.GET("/roles", can(models.PermUsersRead), h.ListRoles)
+			admin.GET("/api-keys", can(models.PermAPIKeysManage), h.ListAPIKeys)
+			admin.POST("/api-keys", can(models.PermAPIKeysManage), h.CreateAPIKey)
+			admin.DELETE("/api-keys/:id", can(models.PermAPIKeysManage), h.RevokeAPIKey)
+			admin.PUT("/sale/config", can(models.PermSaleConfig), h.UpdateSaleConfig)
+			admin.POST("/sale/pause", can(models.PermSalePause), h.PauseSale)
+			admin.POST("/sale/unpause", can(models.PermSalePause), h.UnpauseSale)
+			admin.POST("/tx/estimate", can(models.PermWhitelistWrite), h.EstimateTransaction)
+			admin.GET("/tx/:id", can(models.PermWhitelistRead), h.GetTransaction)
 		}
 	}
 
 	return router
-}
\ No newline at end of file
+}
+
+// gweiToWei converts a fee cap from config; zero means no cap
+func gweiToWei(gwei int) *big.Int {
+	if gwei <= 0 {
+		return nil
+	}
+	return new(big.Int).Mul(big.NewInt(int64(gwei)), big.NewInt(params.GWei))
+}
+
+// etherToWei converts a decimal ether amount from config; empty or zero
+// means no limit
+func etherToWei(ether string) (*big.Int, error) {
+	if ether == "" {
+		return nil, nil
+	}
+	amount, ok := new(big.Rat).SetString(ether)
+	if !ok || amount.Sign() < 0 {
+		return nil, fmt.Errorf("%q is not a non-negative decimal", ether)
+	}
+	if amount.Sign() == 0 {
+		return nil, nil
+	}
+	amount.Mul(amount, new(big.Rat).SetInt64(params.Ether))
+	return new(big.Int).Quo(amount.Num(), amount.Denom()), nil
+}
@@ -0,0 +1,193 @@
+// Command whitelistctl is a small admin client for the whitelist API.
+// It authenticates with an API key carrying the whitelist:read scope for
+// exports and whitelist:write for imports.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const usage = `Usage: whitelistctl <command> [flags]
+
+Commands:
+  import    Upload a CSV or JSON whitelist file and show the diff
+  export    Download the whitelist, optionally as of a past block
+
+Run "whitelistctl <command> -h" for command flags.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "import":
+		err = runImport(os.Args[2:])
+	case "export":
+		err = runExport(os.Args[2:])
+	case "-h", "--help", "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// runImport uploads a whitelist file. Without --apply the server only
+// validates the file and reports what would change.
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	apiURL, apiKey := apiFlags(fs)
+	format := fs.String("format", "", "file format: csv or json (defaults to the file extension)")
+	apply := fs.Bool("apply", false, "apply the changes instead of doing a dry run")
+	replace := fs.Bool("replace", false, "remove whitelisted addresses that are not in the file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: whitelistctl import [flags] FILE")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *apiKey == "" {
+		return errNoAPIKey
+	}
+
+	path := fs.Arg(0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	query := url.Values{}
+	query.Set("format", *format)
+	query.Set("dry_run", fmt.Sprint(!*apply))
+	query.Set("replace", fmt.Sprint(*replace))
+	endpoint := strings.TrimRight(*apiURL, "/") + "/v1/admin/whitelist/import?" + query.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", *apiKey)
+	if *format == "json" {
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req.Header.Set("Content-Type", "text/csv")
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var pretty bytes.Buffer
+	if json.Indent(&pretty, body, "", "  ") == nil {
+		body = pretty.Bytes()
+	}
+	fmt.Println(string(body))
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	return nil
+}
+
+// runExport downloads the whitelist to a file or stdout
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	apiURL, apiKey := apiFlags(fs)
+	format := fs.String("format", "csv", "export format: csv, json or merkle")
+	block := fs.Uint64("block", 0, "export the whitelist as of this block (default: current state)")
+	output := fs.String("o", "", "write to this file instead of stdout")
+	fs.Parse(args)
+
+	if *apiKey == "" {
+		return errNoAPIKey
+	}
+
+	query := url.Values{}
+	query.Set("format", *format)
+	if *block > 0 {
+		query.Set("block", fmt.Sprint(*block))
+	}
+	endpoint := strings.TrimRight(*apiURL, "/") + "/v1/admin/whitelist/export?" + query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", *apiKey)
+
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	out := io.Writer(os.Stdout)
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
+
+var errNoAPIKey = errors.New("an API key is required (--key or WHITELIST_API_KEY)")
+
+// apiFlags registers the connection flags shared by all commands
+func apiFlags(fs *flag.FlagSet) (apiURL, apiKey *string) {
+	apiURL = fs.String("api", envOr("WHITELIST_API_URL", "http://localhost:8080"), "API base URL")
+	apiKey = fs.String("key", os.Getenv("WHITELIST_API_KEY"), "API key (defaults to $WHITELIST_API_KEY)")
+	return apiURL, apiKey
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
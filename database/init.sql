@@ -14,7 +14,7 @@ CREATE TABLE IF NOT EXISTS whitelist (
 -- Purchases table - stores all token purchases
 CREATE TABLE IF NOT EXISTS purchases (
     id SERIAL PRIMARY KEY,
-    tx_hash VARCHAR(66) UNIQUE NOT NULL,  -- Transaction hash
+    tx_hash VARCHAR(66) NOT NULL,  -- Transaction hash, unique together with the log index
     buyer_address VARCHAR(42) NOT NULL,   -- Who bought
     token_amount DECIMAL(20,0) NOT NULL,  -- How many tokens
     eth_amount DECIMAL(20,0) NOT NULL,    -- How much ETH paid
@@ -33,7 +33,6 @@ CREATE TABLE IF NOT EXISTS admin_users (
 -- Create indexes for better performance
 CREATE INDEX IF NOT EXISTS idx_whitelist_address ON whitelist(address);
 CREATE INDEX IF NOT EXISTS idx_purchases_buyer ON purchases(buyer_address);
-CREATE INDEX IF NOT EXISTS idx_purchases_tx_hash ON purchases(tx_hash);
 
 -- Insert a default admin user (password: admin123)
 INSERT INTO admin_users (username, email, password_hash) 
@@ -3,11 +3,14 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
 )
 
+const defaultJWTSecret = "your-secret-key"
+
 type Config struct {
 	// Server configuration
 	Port        string
@@ -18,19 +21,55 @@ type Config struct {
 	RedisURL    string
 
 	// Blockchain configuration
-	BlockchainRPCURL string
-	BlockchainWSURL  string
-	ContractAddress  string
-	TokenAddress     string
-	PrivateKey       string
+	BlockchainRPCURL    string
+	BlockchainWSURL     string
+	ContractAddress     string
+	TokenAddress        string
+	PrivateKey          string
+	MulticallAddress    string
+	ContractDeployBlock int
+	IndexerPollSec      int
+	IndexerMaxRange     int
+	Confirmations       int
+	SaleArtifactPath    string
+	TokenArtifactPath   string
+	TxPollSec           int
+	MaxFeeGwei          int
+	MaxPriorityFeeGwei  int
+	TxBumpBlocks        int
+	TxBumpPercent       int
+	GasLimitMultiplier  float64
+	MaxTxCostEth        string
+
+	// Whitelist
+	WhitelistMode        string
+	ReconcileIntervalMin int
+	ReconcileBatchSize   int
+	ReconcileAutoRepair  bool
+	BatchChunkSize       int
+	BatchMaxOperations   int
+	ScheduleIntervalSec  int
 
 	// JWT configuration
-	JWTSecret    string
-	JWTExpiryHrs int
+	JWTSecret          string
+	JWTAccessTTLMin    int
+	RefreshTokenTTLHrs int
+	JWTSigningMethod   string
+	JWTPrivateKeyPath  string
+	JWTPublicKeyPath   string
+	JWTIssuer          string
+	JWTAudience        string
+
+	// Sign-In with Ethereum configuration
+	SIWEDomain      string
+	SIWEURI         string
+	SIWEStatement   string
+	SIWENonceTTLMin int
+	AdminAddresses  []string
 
 	// External services
-	EtherscanAPIKey  string
-	CoinGeckoAPIKey  string
+	EtherscanAPIKey string
+	CoinGeckoAPIKey string
 
 	// Monitoring
 	SentryDSN string
@@ -39,6 +78,8 @@ type Config struct {
 	// Rate limiting
 	RateLimitRPS   int
 	RateLimitBurst int
+	// Nonces a client IP may request per minute
+	NonceRateLimitPerMin int
 
 	// CORS settings
 	AllowedOrigins []string
@@ -60,15 +101,51 @@ func Load() *Config {
 		RedisURL:    getEnv("REDIS_URL", "redis://localhost:6379"),
 
 		// Blockchain
-		BlockchainRPCURL: getEnv("BLOCKCHAIN_RPC_URL", "http://localhost:8545"),
-		BlockchainWSURL:  getEnv("BLOCKCHAIN_WS_URL", "ws://localhost:8545"),
-		ContractAddress:  getEnv("CONTRACT_ADDRESS", ""),
-		TokenAddress:     getEnv("TOKEN_ADDRESS", ""),
-		PrivateKey:       getEnv("PRIVATE_KEY", ""),
+		BlockchainRPCURL:    getEnv("BLOCKCHAIN_RPC_URL", "http://localhost:8545"),
+		BlockchainWSURL:     getEnv("BLOCKCHAIN_WS_URL", "ws://localhost:8545"),
+		ContractAddress:     getEnv("CONTRACT_ADDRESS", ""),
+		TokenAddress:        getEnv("TOKEN_ADDRESS", ""),
+		PrivateKey:          getEnv("PRIVATE_KEY", ""),
+		MulticallAddress:    getEnv("MULTICALL_ADDRESS", "0xcA11bde05977b3631167028862bE2a173976CA11"), // Multicall3's address on most EVM chains
+		ContractDeployBlock: getEnvAsInt("CONTRACT_DEPLOY_BLOCK", 0),
+		IndexerPollSec:      getEnvAsInt("INDEXER_POLL_SECONDS", 15),
+		IndexerMaxRange:     getEnvAsInt("INDEXER_MAX_BLOCK_RANGE", 2000),
+		Confirmations:       getEnvAsInt("CONFIRMATIONS", 12),
+		SaleArtifactPath:    getEnv("SALE_ARTIFACT_PATH", ""),
+		TokenArtifactPath:   getEnv("TOKEN_ARTIFACT_PATH", ""),
+		TxPollSec:           getEnvAsInt("TX_POLL_SECONDS", 15),
+		MaxFeeGwei:          getEnvAsInt("MAX_FEE_PER_GAS_GWEI", 200),
+		MaxPriorityFeeGwei:  getEnvAsInt("MAX_PRIORITY_FEE_GWEI", 5),
+		TxBumpBlocks:        getEnvAsInt("TX_BUMP_AFTER_BLOCKS", 5),
+		TxBumpPercent:       getEnvAsInt("TX_BUMP_PERCENT", 20),
+		GasLimitMultiplier:  getEnvAsFloat("GAS_LIMIT_MULTIPLIER", 1.2),
+		MaxTxCostEth:        getEnv("MAX_TX_COST_ETH", "0.5"),
+
+		// Whitelist
+		WhitelistMode:        getEnv("WHITELIST_MODE", "mapping"),
+		ReconcileIntervalMin: getEnvAsInt("RECONCILE_INTERVAL_MINUTES", 15),
+		ReconcileBatchSize:   getEnvAsInt("RECONCILE_BATCH_SIZE", 200),
+		ReconcileAutoRepair:  getEnvAsBool("RECONCILE_AUTO_REPAIR", false),
+		BatchChunkSize:       getEnvAsInt("BATCH_CHUNK_SIZE", 200),
+		BatchMaxOperations:   getEnvAsInt("BATCH_MAX_OPERATIONS", 50000),
+		ScheduleIntervalSec:  getEnvAsInt("SCHEDULE_INTERVAL_SECONDS", 30),
 
 		// JWT
-		JWTSecret:    getEnv("JWT_SECRET", "your-secret-key"),
-		JWTExpiryHrs: getEnvAsInt("JWT_EXPIRY_HOURS", 24),
+		JWTSecret:          getEnv("JWT_SECRET", defaultJWTSecret),
+		JWTAccessTTLMin:    getEnvAsInt("JWT_ACCESS_TTL_MINUTES", 15),
+		RefreshTokenTTLHrs: getEnvAsInt("REFRESH_TOKEN_TTL_HOURS", 168),
+		JWTSigningMethod:   getEnv("JWT_SIGNING_METHOD", "HS256"),
+		JWTPrivateKeyPath:  getEnv("JWT_PRIVATE_KEY_PATH", ""),
+		JWTPublicKeyPath:   getEnv("JWT_PUBLIC_KEY_PATH", ""),
+		JWTIssuer:          getEnv("JWT_ISSUER", "whitelist-token-backend"),
+		JWTAudience:        getEnv("JWT_AUDIENCE", "whitelist-token-api"),
+
+		// Sign-In with Ethereum
+		SIWEDomain:      getEnv("SIWE_DOMAIN", "localhost:3000"),
+		SIWEURI:         getEnv("SIWE_URI", "http://localhost:3000"),
+		SIWEStatement:   getEnv("SIWE_STATEMENT", "Sign in to the WhitelistToken admin console."),
+		SIWENonceTTLMin: getEnvAsInt("SIWE_NONCE_TTL_MINUTES", 10),
+		AdminAddresses:  getEnvAsSlice("ADMIN_ADDRESSES", []string{"0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"}),
 
 		// External services
 		EtherscanAPIKey: getEnv("ETHERSCAN_API_KEY", ""),
@@ -79,8 +156,9 @@ func Load() *Config {
 		LogLevel:  getEnv("LOG_LEVEL", "info"),
 
 		// Rate limiting
-		RateLimitRPS:   getEnvAsInt("RATE_LIMIT_RPS", 10),
-		RateLimitBurst: getEnvAsInt("RATE_LIMIT_BURST", 20),
+		RateLimitRPS:         getEnvAsInt("RATE_LIMIT_RPS", 10),
+		RateLimitBurst:       getEnvAsInt("RATE_LIMIT_BURST", 20),
+		NonceRateLimitPerMin: getEnvAsInt("NONCE_RATE_LIMIT_PER_MIN", 10),
 
 		// CORS
 		AllowedOrigins: getEnvAsSlice("ALLOWED_ORIGINS", []string{"http://localhost:3000", "http://localhost:3001"}),
@@ -105,6 +183,13 @@ func (c *Config) validate() {
 		}
 	}
 
+	if c.IsProduction() && c.JWTSigningMethod == "HS256" && c.JWTSecret == defaultJWTSecret {
+		logrus.Fatal("JWT_SECRET must be changed from its default value in production")
+	}
+	if c.JWTSigningMethod != "HS256" && c.JWTPrivateKeyPath == "" {
+		logrus.Fatalf("JWT_PRIVATE_KEY_PATH is required for %s signing", c.JWTSigningMethod)
+	}
+
 	// Warn about missing optional but recommended variables
 	optional := map[string]string{
 		"CONTRACT_ADDRESS": c.ContractAddress,
@@ -142,6 +227,14 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
 func getEnvAsBool(key string, defaultValue bool) bool {
 	valueStr := getEnv(key, "")
 	if value, err := strconv.ParseBool(valueStr); err == nil {
@@ -155,14 +248,13 @@ func getEnvAsSlice(key string, defaultValue []string) []string {
 	if valueStr == "" {
 		return defaultValue
 	}
-	
+
 	// Simple comma-separated parsing
-	// In production, consider using a more robust parsing method
 	result := []string{}
-	for _, v := range []string{valueStr} {
-		if v != "" {
+	for _, v := range strings.Split(valueStr, ",") {
+		if v = strings.TrimSpace(v); v != "" {
 			result = append(result, v)
 		}
 	}
 	return result
-}
\ No newline at end of file
+}
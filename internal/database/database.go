@@ -81,15 +81,91 @@ func Migrate(databaseURL string) error {
 
 // AutoMigrate runs GORM auto-migration for models
 func AutoMigrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	err := db.AutoMigrate(
 		&models.User{},
+		&models.Role{},
+		&models.Permission{},
+		&models.UserRole{},
+		&models.APIKey{},
 		&models.WhitelistEntry{},
+		&models.AllocationTier{},
+		&models.WhitelistDrift{},
+		&models.MerkleRoot{},
+		&models.MerkleLeaf{},
+		&models.BatchJob{},
+		&models.BatchChunk{},
+		&models.Transaction{},
+		&models.SignerNonce{},
+		&models.ScheduledChange{},
+		&models.WhitelistApplication{},
+		&models.WhitelistEvent{},
+		&models.ChainEvent{},
+		&models.IndexedBlock{},
+		&models.IndexerCursor{},
 		&models.Purchase{},
 		&models.SaleConfig{},
 		&models.ActivityLog{},
 		&models.SystemLog{},
 		&models.DailyStats{},
 	)
+	if err != nil {
+		return err
+	}
+	return dropLegacyPurchaseIndexes(db)
+}
+
+// dropLegacyPurchaseIndexes removes the unique constraints on purchases.tx_hash
+// left by init.sql and older models. A transaction can emit several purchase
+// logs, so purchases are unique per tx hash and log index; AutoMigrate never
+// drops indexes itself.
+func dropLegacyPurchaseIndexes(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if migrator.HasConstraint(&models.Purchase{}, "purchases_tx_hash_key") {
+		if err := migrator.DropConstraint(&models.Purchase{}, "purchases_tx_hash_key"); err != nil {
+			return fmt.Errorf("failed to drop purchases_tx_hash_key: %w", err)
+		}
+	}
+	if migrator.HasIndex(&models.Purchase{}, "idx_purchases_tx_hash") {
+		if err := migrator.DropIndex(&models.Purchase{}, "idx_purchases_tx_hash"); err != nil {
+			return fmt.Errorf("failed to drop idx_purchases_tx_hash: %w", err)
+		}
+	}
+	return nil
+}
+
+// SeedRoles creates the built-in roles and permissions. It only adds missing
+// rows and links, so permissions granted manually are left untouched.
+func SeedRoles(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		permissions := make(map[string]models.Permission, len(models.PermissionDescriptions))
+		for name, description := range models.PermissionDescriptions {
+			permission := models.Permission{Name: name, Description: description}
+			if err := tx.Where(models.Permission{Name: name}).FirstOrCreate(&permission).Error; err != nil {
+				return fmt.Errorf("failed to seed permission %s: %w", name, err)
+			}
+			permissions[name] = permission
+		}
+
+		for _, defaultRole := range models.DefaultRoles {
+			role := models.Role{Name: defaultRole.Name, Description: defaultRole.Description}
+			if err := tx.Where(models.Role{Name: defaultRole.Name}).FirstOrCreate(&role).Error; err != nil {
+				return fmt.Errorf("failed to seed role %s: %w", defaultRole.Name, err)
+			}
+
+			rolePermissions := make([]models.Permission, 0, len(defaultRole.Permissions))
+			for _, name := range defaultRole.Permissions {
+				rolePermissions = append(rolePermissions, permissions[name])
+			}
+			if len(rolePermissions) == 0 {
+				continue
+			}
+			if err := tx.Model(&role).Association("Permissions").Append(rolePermissions); err != nil {
+				return fmt.Errorf("failed to seed permissions for role %s: %w", defaultRole.Name, err)
+			}
+		}
+
+		return nil
+	})
 }
 
 // InitializeRedis initializes Redis connection
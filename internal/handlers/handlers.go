@@ -2,11 +2,18 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"whitelist-token-backend/internal/models"
 	"whitelist-token-backend/internal/services"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
@@ -15,6 +22,14 @@ import (
 type Handlers struct {
 	whitelistService  *services.WhitelistService
 	authService      *services.AuthService
+	rbacService      *services.RBACService
+	reconcilerService *services.ReconcilerService
+	batchService     *services.BatchService
+	schedulerService *services.SchedulerService
+	applicationService *services.ApplicationService
+	importService    *services.ImportService
+	exportService    *services.ExportService
+	apiKeyService    *services.APIKeyService
 	analyticsService *services.AnalyticsService
 	blockchainService *services.BlockchainService
 	logger           *logrus.Logger
@@ -24,6 +39,14 @@ type Handlers struct {
 func NewHandlers(
 	whitelistService *services.WhitelistService,
 	authService *services.AuthService,
+	rbacService *services.RBACService,
+	reconcilerService *services.ReconcilerService,
+	batchService *services.BatchService,
+	schedulerService *services.SchedulerService,
+	applicationService *services.ApplicationService,
+	importService *services.ImportService,
+	exportService *services.ExportService,
+	apiKeyService *services.APIKeyService,
 	analyticsService *services.AnalyticsService,
 	blockchainService *services.BlockchainService,
 	logger *logrus.Logger,
@@ -31,6 +54,14 @@ func NewHandlers(
 	return &Handlers{
 		whitelistService:  whitelistService,
 		authService:      authService,
+		rbacService:      rbacService,
+		reconcilerService: reconcilerService,
+		batchService:     batchService,
+		schedulerService: schedulerService,
+		applicationService: applicationService,
+		importService:    importService,
+		exportService:    exportService,
+		apiKeyService:    apiKeyService,
 		analyticsService: analyticsService,
 		blockchainService: blockchainService,
 		logger:           logger,
@@ -58,6 +89,55 @@ func (h *Handlers) Metrics(c *gin.Context) {
 }
 
 // Auth handlers
+func (h *Handlers) GetNonce(c *gin.Context) {
+	var req struct {
+		Address string `json:"address" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if !common.IsHexAddress(req.Address) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid Ethereum address format",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	msg, err := h.authService.IssueNonce(ctx, req.Address, c.ClientIP())
+	if errors.Is(err, services.ErrTooManyNonceRequests) {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).WithField("address", req.Address).Error("Failed to issue nonce")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to issue nonce",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"nonce": msg.Nonce,
+			"message": msg.String(),
+			"issued_at": msg.IssuedAt,
+			"expires_at": msg.ExpirationTime,
+		},
+	})
+}
+
 func (h *Handlers) Login(c *gin.Context) {
 	var req struct {
 		Address   string `json:"address" binding:"required"`
@@ -73,41 +153,111 @@ func (h *Handlers) Login(c *gin.Context) {
 		return
 	}
 
-	// For demo purposes, check if address is the admin address (deployer)
-	adminAddress := "0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266"
-	if req.Address != adminAddress {
-		c.JSON(http.StatusForbidden, gin.H{
-			"error": "Not authorized as admin",
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := h.authService.Login(ctx, req.Address, req.Message, req.Signature)
+	if err != nil {
+		h.respondAuthError(c, err, req.Address)
+		return
+	}
+
+	tokens, err := h.authService.StartSession(ctx, user)
+	if err != nil {
+		h.logger.WithError(err).WithField("address", user.Address).Error("Failed to start session")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start session",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"token": tokens.AccessToken,
+			"refresh_token": tokens.RefreshToken,
+			"token_type": tokens.TokenType,
+			"expires_at": tokens.ExpiresAt,
+			"refresh_expires_at": tokens.RefreshExpiresAt,
+			"address": user.Address,
+			"role": tokens.Role,
+		},
+	})
+}
+
+func (h *Handlers) RefreshToken(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
 		})
 		return
 	}
 
-	// In production, you would verify the signature here
-	// For demo, we'll just issue a simple JWT token
-	token := "demo-admin-token-" + req.Address + "-" + string(rune(time.Now().Unix()))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tokens, err := h.authService.RefreshSession(ctx, req.RefreshToken)
+	if err != nil {
+		h.respondAuthError(c, err, "")
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data": gin.H{
-			"token": token,
-			"address": req.Address,
-			"role": "admin",
+			"token": tokens.AccessToken,
+			"refresh_token": tokens.RefreshToken,
+			"token_type": tokens.TokenType,
+			"expires_at": tokens.ExpiresAt,
+			"refresh_expires_at": tokens.RefreshExpiresAt,
+			"role": tokens.Role,
 		},
 	})
 }
 
-func (h *Handlers) VerifySignature(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "verify signature endpoint"})
+func (h *Handlers) Logout(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.authService.Logout(ctx, req.RefreshToken); err != nil {
+		h.respondAuthError(c, err, "")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Logged out successfully",
+	})
 }
 
-// Whitelist handlers
-func (h *Handlers) GetWhitelistStatus(c *gin.Context) {
-	address := c.Param("address")
-	
-	// Validate address format
-	if len(address) != 42 || address[:2] != "0x" {
+func (h *Handlers) VerifySignature(c *gin.Context) {
+	var req struct {
+		Address   string `json:"address" binding:"required"`
+		Message   string `json:"message" binding:"required"`
+		Signature string `json:"signature" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid Ethereum address format",
+			"error": "Invalid request body",
+			"details": err.Error(),
 		})
 		return
 	}
@@ -115,12 +265,11 @@ func (h *Handlers) GetWhitelistStatus(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// For now, use empty merkle proof - this can be enhanced later
-	isWhitelisted, err := h.blockchainService.IsWhitelisted(ctx, address, []string{})
-	if err != nil {
-		h.logger.WithError(err).WithField("address", address).Error("Failed to check whitelist status")
+	valid, err := h.authService.VerifySignature(ctx, req.Address, req.Message, req.Signature)
+	if err != nil && !errors.Is(err, services.ErrInvalidSignature) {
+		h.logger.WithError(err).WithField("address", req.Address).Error("Failed to verify signature")
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to check whitelist status",
+			"error": "Failed to verify signature",
 		})
 		return
 	}
@@ -128,82 +277,159 @@ func (h *Handlers) GetWhitelistStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data": gin.H{
-			"address": address,
-			"isWhitelisted": isWhitelisted,
+			"address": req.Address,
+			"valid": valid,
 		},
 	})
 }
 
-func (h *Handlers) VerifyWhitelist(c *gin.Context) {
+// respondAuthError maps authentication failures to HTTP responses
+func (h *Handlers) respondAuthError(c *gin.Context, err error, address string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidMessage),
+		errors.Is(err, services.ErrInvalidSignature),
+		errors.Is(err, services.ErrInvalidNonce),
+		errors.Is(err, services.ErrMessageExpired),
+		errors.Is(err, services.ErrInvalidRefreshToken),
+		errors.Is(err, services.ErrRefreshTokenReused),
+		errors.Is(err, services.ErrSessionRevoked):
+		h.logger.WithError(err).WithField("address", address).Warn("Authentication rejected")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication failed",
+			"details": err.Error(),
+		})
+	default:
+		h.logger.WithError(err).WithField("address", address).Error("Authentication error")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Authentication failed",
+		})
+	}
+}
+
+// Whitelist handlers
+func (h *Handlers) GetWhitelistStatus(c *gin.Context) {
 	address := c.Param("address")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	status, err := h.whitelistService.Status(ctx, address)
+	if err != nil {
+		h.respondWhitelistError(c, err, address, nil, "Failed to check whitelist status")
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"address": address,
-		"verified": false,
+		"success": true,
+		"data": status,
 	})
 }
 
-// Sale handlers
-func (h *Handlers) GetSaleInfo(c *gin.Context) {
+// VerifyWhitelist checks a merkle proof against the active root. The proof
+// and allocation can be supplied as query parameters; by default the stored
+// proof for the address is used.
+func (h *Handlers) VerifyWhitelist(c *gin.Context) {
+	address := c.Param("address")
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Get token information instead of sale info since we don't have a sale contract
-	// Get token balance for the configured addresses
-	_, err := h.blockchainService.GetTokenBalance(ctx, "0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266")
+	allocation := c.Query("allocation")
+	var proof []string
+	if raw := c.Query("proof"); raw != "" {
+		proof = strings.Split(raw, ",")
+	}
+
+	if allocation == "" || proof == nil {
+		stored, err := h.whitelistService.GetMerkleProof(ctx, address)
+		if err != nil {
+			if errors.Is(err, services.ErrNotInMerkleTree) {
+				c.JSON(http.StatusOK, gin.H{
+					"success": true,
+					"data": gin.H{
+						"address": address,
+						"verified": false,
+					},
+				})
+				return
+			}
+			h.respondMerkleError(c, err, address)
+			return
+		}
+		if allocation == "" {
+			allocation = stored.Allocation
+		}
+		if proof == nil {
+			proof = stored.Proof
+		}
+	}
+
+	verified, root, err := h.whitelistService.VerifyMerkleProof(ctx, address, allocation, proof)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to get token balance")
+		h.respondMerkleError(c, err, address)
+		return
 	}
 
-	// Return mock sale info with token data
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data": gin.H{
-			"tokenAddress": "0x0165878A594ca255338adfa4d48449f69242Eb8F",
-			"treasury": "0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266",
-			"tokenPrice": "0", // No sale price
-			"minPurchase": "0", 
-			"maxPurchase": "1000000000000000000000000", // 1M tokens
-			"maxSupply": "1000000000000000000000000000", // 1B tokens
-			"startTime": 0,
-			"endTime": 0,
-			"isPaused": false,
-			"isActive": false, // No sale active
-			"totalSold": "0",
-			"claimEnabled": false,
-			"claimStartTime": 0,
+			"address": address,
+			"allocation": allocation,
+			"verified": verified,
+			"root": root.Root,
+			"version": root.Version,
 		},
 	})
 }
 
-func (h *Handlers) GetUserPurchases(c *gin.Context) {
+func (h *Handlers) GetMerkleProof(c *gin.Context) {
 	address := c.Param("address")
-	c.JSON(http.StatusOK, gin.H{
-		"address": address,
-		"purchases": []interface{}{},
-	})
-}
 
-func (h *Handlers) GetSaleStats(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "sale stats endpoint"})
-}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-// Analytics handlers (placeholders)
-func (h *Handlers) GetAnalyticsOverview(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "analytics overview endpoint"})
-}
+	proof, err := h.whitelistService.GetMerkleProof(ctx, address)
+	if err != nil {
+		h.respondMerkleError(c, err, address)
+		return
+	}
 
-func (h *Handlers) GetSalesAnalytics(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "sales analytics endpoint"})
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": proof,
+	})
 }
 
-func (h *Handlers) GetUserAnalytics(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "user analytics endpoint"})
+// respondMerkleError maps merkle proof failures to HTTP responses
+func (h *Handlers) respondMerkleError(c *gin.Context, err error, address string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAddress):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid Ethereum address format",
+		})
+	case errors.Is(err, services.ErrInvalidAllocation):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrNoMerkleRoot), errors.Is(err, services.ErrNotInMerkleTree):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		h.logger.WithError(err).WithField("address", address).Error("Failed to load merkle proof")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to load merkle proof",
+		})
+	}
 }
 
-// Admin handlers
-func (h *Handlers) AddToWhitelist(c *gin.Context) {
+// Sale handlers
+// PrepareApplication returns the message a wallet signs to apply for the
+// whitelist with the given form fields
+func (h *Handlers) PrepareApplication(c *gin.Context) {
 	var req struct {
-		Address string `json:"address" binding:"required"`
+		Address string            `json:"address" binding:"required"`
+		Fields  map[string]string `json:"fields"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -214,38 +440,28 @@ func (h *Handlers) AddToWhitelist(c *gin.Context) {
 		return
 	}
 
-	// Validate address format
-	if len(req.Address) != 42 || req.Address[:2] != "0x" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid Ethereum address format",
-		})
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	_, err := h.blockchainService.AddToWhitelist(ctx, []string{req.Address})
+	msg, err := h.applicationService.PrepareMessage(ctx, req.Address, req.Fields)
 	if err != nil {
-		h.logger.WithError(err).WithField("address", req.Address).Error("Failed to add to whitelist")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to add address to whitelist",
-		})
+		h.respondApplicationError(c, err, req.Address, "Failed to prepare application")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "Address added to whitelist successfully",
-		"data": gin.H{
-			"address": req.Address,
-		},
+		"data": msg,
 	})
 }
 
-func (h *Handlers) RemoveFromWhitelist(c *gin.Context) {
+// SubmitApplication stores a signed whitelist application for admin review
+func (h *Handlers) SubmitApplication(c *gin.Context) {
 	var req struct {
-		Address string `json:"address" binding:"required"`
+		Address   string            `json:"address" binding:"required"`
+		Fields    map[string]string `json:"fields"`
+		Nonce     string            `json:"nonce" binding:"required"`
+		Signature string            `json:"signature" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -256,51 +472,1381 @@ func (h *Handlers) RemoveFromWhitelist(c *gin.Context) {
 		return
 	}
 
-	// Validate address format
-	if len(req.Address) != 42 || req.Address[:2] != "0x" {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	application, err := h.applicationService.Submit(ctx, req.Address, req.Fields, req.Nonce, req.Signature, c.ClientIP())
+	if err != nil {
+		h.respondApplicationError(c, err, req.Address, "Failed to submit application")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Application submitted",
+		"data": gin.H{
+			"id": application.ID,
+			"status": application.Status,
+		},
+	})
+}
+
+func (h *Handlers) respondApplicationError(c *gin.Context, err error, address, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAddress):
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Invalid Ethereum address format",
 		})
-		return
+	case errors.Is(err, services.ErrInvalidApplication), errors.Is(err, services.ErrInvalidAllocation), errors.Is(err, services.ErrInvalidTierName):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidSignature), errors.Is(err, services.ErrApplicationNonceUsed):
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrApplicationNotFound), errors.Is(err, services.ErrTierNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrApplicationPending), errors.Is(err, services.ErrApplicationReviewed), errors.Is(err, services.ErrAlreadyWhitelisted):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		h.logger.WithError(err).WithField("address", address).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": message,
+		})
 	}
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+func (h *Handlers) GetSaleInfo(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	_, err := h.blockchainService.RemoveFromWhitelist(ctx, []string{req.Address})
+	info, err := h.blockchainService.GetSaleInfo(ctx)
 	if err != nil {
-		h.logger.WithError(err).WithField("address", req.Address).Error("Failed to remove from whitelist")
+		h.logger.WithError(err).Error("Failed to get sale info")
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to remove address from whitelist",
+			"error": "Failed to get sale info",
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "Address removed from whitelist successfully",
-		"data": gin.H{
-			"address": req.Address,
-		},
+		"data": info,
 	})
 }
 
-func (h *Handlers) BatchUpdateWhitelist(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "batch update whitelist endpoint"})
+func (h *Handlers) GetUserPurchases(c *gin.Context) {
+	address := c.Param("address")
+	c.JSON(http.StatusOK, gin.H{
+		"address": address,
+		"purchases": []interface{}{},
+	})
 }
 
-func (h *Handlers) GetAllUsers(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "get all users endpoint"})
+func (h *Handlers) GetSaleStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"message": "sale stats endpoint"})
 }
 
-func (h *Handlers) UpdateSaleConfig(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "update sale config endpoint"})
+// Analytics handlers (placeholders)
+func (h *Handlers) GetAnalyticsOverview(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"message": "analytics overview endpoint"})
 }
 
-func (h *Handlers) PauseSale(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "pause sale endpoint"})
+func (h *Handlers) GetSalesAnalytics(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"message": "sales analytics endpoint"})
 }
 
-func (h *Handlers) UnpauseSale(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"message": "unpause sale endpoint"})
-}
\ No newline at end of file
+func (h *Handlers) GetUserAnalytics(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"message": "user analytics endpoint"})
+}
+
+// Admin handlers
+func (h *Handlers) AddToWhitelist(c *gin.Context) {
+	var req struct {
+		Address       string `json:"address" binding:"required"`
+		MaxAllocation string `json:"max_allocation"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	entry, err := h.whitelistService.Add(ctx, req.Address, req.MaxAllocation, c.GetString("user_address"))
+	if err != nil {
+		h.respondWhitelistError(c, err, req.Address, entry, "Failed to add address to whitelist")
+		return
+	}
+
+	if entry.TxID != 0 {
+		c.JSON(http.StatusAccepted, gin.H{
+			"success": true,
+			"message": "Whitelist transaction sent",
+			"tx_id": entry.TxID,
+			"data": entry,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Address added to whitelist successfully",
+		"data": entry,
+	})
+}
+
+func (h *Handlers) RemoveFromWhitelist(c *gin.Context) {
+	var req struct {
+		Address string `json:"address" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	entry, err := h.whitelistService.Remove(ctx, req.Address, c.GetString("user_address"))
+	if err != nil {
+		h.respondWhitelistError(c, err, req.Address, entry, "Failed to remove address from whitelist")
+		return
+	}
+
+	if entry.TxID != 0 {
+		c.JSON(http.StatusAccepted, gin.H{
+			"success": true,
+			"message": "Whitelist transaction sent",
+			"tx_id": entry.TxID,
+			"data": entry,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Address removed from whitelist successfully",
+		"data": entry,
+	})
+}
+
+func (h *Handlers) ListWhitelistEntries(c *gin.Context) {
+	filter := services.WhitelistFilter{
+		Status: c.Query("status"),
+	}
+	filter.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	filter.PageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if v := c.Query("whitelisted"); v != "" {
+		whitelisted, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid whitelisted filter",
+			})
+			return
+		}
+		filter.Whitelisted = &whitelisted
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	entries, total, err := h.whitelistService.List(ctx, filter)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list whitelist entries")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list whitelist entries",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": entries,
+		"total": total,
+	})
+}
+
+func (h *Handlers) GetWhitelistEntry(c *gin.Context) {
+	address := c.Param("address")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	entry, err := h.whitelistService.Get(ctx, address)
+	if err != nil {
+		h.respondWhitelistError(c, err, address, nil, "Failed to load whitelist entry")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": entry,
+	})
+}
+
+func (h *Handlers) GenerateMerkleRoot(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	root, err := h.whitelistService.GenerateMerkleRoot(ctx, c.GetString("user_address"))
+	if err != nil {
+		if errors.Is(err, services.ErrEmptyWhitelist) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to generate merkle root")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate merkle root",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Merkle root generated successfully",
+		"data": root,
+	})
+}
+
+func (h *Handlers) ListMerkleRoots(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	roots, err := h.whitelistService.ListMerkleRoots(ctx)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list merkle roots")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list merkle roots",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": roots,
+	})
+}
+
+func (h *Handlers) GetWhitelistDrift(c *gin.Context) {
+	includeResolved, _ := strconv.ParseBool(c.DefaultQuery("include_resolved", "false"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	drifts, err := h.reconcilerService.ListDrift(ctx, includeResolved)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list whitelist drift")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list whitelist drift",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": drifts,
+		"total": len(drifts),
+	})
+}
+
+func (h *Handlers) ReconcileWhitelist(c *gin.Context) {
+	// Repairs write to the on-chain mapping, which merkle mode does not use
+	if h.whitelistService.Mode() != services.WhitelistModeMapping {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "whitelist reconciliation is only available in mapping mode",
+		})
+		return
+	}
+
+	// A full pass can outlast the request timeout, so it runs in the background
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+		defer cancel()
+
+		if _, err := h.reconcilerService.RunOnce(ctx); err != nil {
+			if errors.Is(err, services.ErrLockHeld) {
+				h.logger.Info("Whitelist reconciliation already in progress")
+				return
+			}
+			h.logger.WithError(err).Error("Failed to reconcile whitelist")
+		}
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": "Whitelist reconciliation started",
+	})
+}
+
+// respondWhitelistError maps whitelist failures to HTTP responses. When the
+// entry was recorded, it is returned so callers can see the tx hash and status.
+func (h *Handlers) respondWhitelistError(c *gin.Context, err error, address string, entry *models.WhitelistEntry, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAddress):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid Ethereum address format",
+		})
+	case errors.Is(err, services.ErrInvalidAllocation):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrWhitelistEntryNotFound), errors.Is(err, services.ErrNotWhitelisted), errors.Is(err, services.ErrTierNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidTierName), errors.Is(err, services.ErrInvalidWindow):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrTierInUse):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrGasLimitExceeded), errors.Is(err, services.ErrTxCostExceeded),
+		errors.Is(err, services.ErrExecutionReverted), errors.Is(err, services.ErrFeeCapExceeded):
+		h.logger.WithError(err).WithField("address", address).Warn(message)
+		response := gin.H{
+			"error": message,
+			"details": err.Error(),
+		}
+		if entry != nil {
+			response["data"] = entry
+		}
+		c.JSON(http.StatusUnprocessableEntity, response)
+	default:
+		h.logger.WithError(err).WithField("address", address).Error(message)
+		response := gin.H{
+			"error": message,
+		}
+		if entry != nil {
+			response["data"] = entry
+		}
+		c.JSON(http.StatusInternalServerError, response)
+	}
+}
+
+func (h *Handlers) ListTiers(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tiers, err := h.whitelistService.ListTiers(ctx)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list allocation tiers")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list allocation tiers",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": tiers,
+	})
+}
+
+// SaveTier creates or updates the allocation tier named in the path
+func (h *Handlers) SaveTier(c *gin.Context) {
+	var req struct {
+		Cap         string `json:"cap" binding:"required"`
+		Description string `json:"description"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tier, err := h.whitelistService.SaveTier(ctx, c.Param("name"), req.Cap, req.Description)
+	if err != nil {
+		h.respondWhitelistError(c, err, "", nil, "Failed to save allocation tier")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": tier,
+	})
+}
+
+func (h *Handlers) DeleteTier(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.whitelistService.DeleteTier(ctx, c.Param("name")); err != nil {
+		h.respondWhitelistError(c, err, "", nil, "Failed to delete allocation tier")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Allocation tier deleted",
+	})
+}
+
+// AssignTier sets the allocation tier of a whitelist entry. An empty tier
+// removes the assignment.
+func (h *Handlers) AssignTier(c *gin.Context) {
+	address := c.Param("address")
+
+	var req struct {
+		Tier string `json:"tier"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	entry, err := h.whitelistService.AssignTier(ctx, address, req.Tier)
+	if err != nil {
+		h.respondWhitelistError(c, err, address, nil, "Failed to assign allocation tier")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": entry,
+	})
+}
+
+// SyncUsedAllocations recomputes every entry's used allocation from confirmed purchases
+func (h *Handlers) SyncUsedAllocations(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	updated, err := h.whitelistService.SyncUsedAllocation(ctx)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to sync used allocations")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to sync used allocations",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"updated": updated,
+		},
+	})
+}
+
+// ScheduleWhitelist whitelists an address for a time window. The add and
+// removal are submitted by the scheduler when they fall due.
+func (h *Handlers) ScheduleWhitelist(c *gin.Context) {
+	var req services.ScheduleRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	entry, changes, err := h.schedulerService.Schedule(ctx, req, c.GetString("user_address"))
+	if err != nil {
+		h.respondWhitelistError(c, err, req.Address, nil, "Failed to schedule whitelist window")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"entry": entry,
+			"scheduled_changes": changes,
+		},
+	})
+}
+
+// ListScheduledChanges returns changes that have not been submitted yet,
+// optionally only those due ?within a duration such as 24h
+func (h *Handlers) ListScheduledChanges(c *gin.Context) {
+	var within time.Duration
+	if raw := c.Query("within"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid within duration",
+			})
+			return
+		}
+		within = d
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	changes, err := h.schedulerService.Upcoming(ctx, within)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list scheduled changes")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list scheduled changes",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": changes,
+	})
+}
+
+func (h *Handlers) CancelScheduledChange(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid scheduled change ID",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.schedulerService.Cancel(ctx, uint(id), c.GetString("user_address")); err != nil {
+		if errors.Is(err, services.ErrScheduledChangeNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		h.logger.WithError(err).WithField("id", id).Error("Failed to cancel scheduled change")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to cancel scheduled change",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Scheduled change cancelled",
+	})
+}
+
+// ListApplications returns the application review queue (?status=pending|approved|rejected)
+func (h *Handlers) ListApplications(c *gin.Context) {
+	filter := services.ApplicationFilter{
+		Status: c.DefaultQuery("status", models.ApplicationStatusPending),
+	}
+	filter.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	filter.PageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", "50"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	applications, total, err := h.applicationService.List(ctx, filter)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list applications")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list applications",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": applications,
+		"total": total,
+	})
+}
+
+// ApproveApplication whitelists the applicant, optionally with an allocation and tier
+func (h *Handlers) ApproveApplication(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid application ID",
+		})
+		return
+	}
+
+	var req struct {
+		MaxAllocation string `json:"max_allocation"`
+		Tier          string `json:"tier"`
+		Note          string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	application, entry, err := h.applicationService.Approve(ctx, uint(id), req.MaxAllocation, req.Tier, c.GetString("user_address"), req.Note)
+	if err != nil {
+		if entry != nil {
+			h.respondWhitelistError(c, err, entry.Address, entry, "Failed to add address to whitelist")
+			return
+		}
+		h.respondApplicationError(c, err, "", "Failed to approve application")
+		return
+	}
+
+	status := http.StatusOK
+	response := gin.H{
+		"success": true,
+		"message": "Application approved",
+		"data": gin.H{
+			"application": application,
+			"entry": entry,
+		},
+	}
+	if entry.TxID != 0 {
+		status = http.StatusAccepted
+		response["tx_id"] = entry.TxID
+	}
+	c.JSON(status, response)
+}
+
+func (h *Handlers) RejectApplication(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid application ID",
+		})
+		return
+	}
+
+	var req struct {
+		Note string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	application, err := h.applicationService.Reject(ctx, uint(id), c.GetString("user_address"), req.Note)
+	if err != nil {
+		h.respondApplicationError(c, err, "", "Failed to reject application")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Application rejected",
+		"data": application,
+	})
+}
+
+// BatchUpdateWhitelist queues a large add/remove batch and returns its job ID.
+// Chunks are submitted in the background; poll GetBatchJob for progress.
+func (h *Handlers) BatchUpdateWhitelist(c *gin.Context) {
+	var req struct {
+		Operations []services.BatchOperation `json:"operations" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	job, validation, err := h.batchService.Submit(ctx, req.Operations, c.GetString("user_address"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrBatchEmpty):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+				"validation": validation,
+			})
+		case errors.Is(err, services.ErrBatchTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": err.Error(),
+			})
+		default:
+			h.logger.WithError(err).Error("Failed to queue batch job")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to queue batch job",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": "Batch job queued",
+		"data": gin.H{
+			"job_id": job.ID,
+			"status": job.Status,
+			"total_operations": job.TotalOperations,
+			"total_chunks": job.TotalChunks,
+			"validation": validation,
+		},
+	})
+}
+
+func (h *Handlers) ListBatchJobs(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	jobs, err := h.batchService.ListJobs(ctx, limit)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list batch jobs")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list batch jobs",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": jobs,
+	})
+}
+
+func (h *Handlers) GetBatchJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid batch job ID",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	job, progress, err := h.batchService.GetJob(ctx, uint(id))
+	if err != nil {
+		if errors.Is(err, services.ErrBatchJobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		h.logger.WithError(err).WithField("job_id", id).Error("Failed to load batch job")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to load batch job",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"job": job,
+			"progress": progress,
+		},
+	})
+}
+
+// ImportWhitelist accepts a CSV or JSON whitelist file, either as a multipart
+// "file" field or as the raw request body. Imports are dry runs unless
+// dry_run=false is given.
+func (h *Handlers) ImportWhitelist(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "true"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid dry_run value",
+		})
+		return
+	}
+	replace, _ := strconv.ParseBool(c.DefaultQuery("replace", "false"))
+
+	format := strings.ToLower(c.Query("format"))
+	body := io.Reader(c.Request.Body)
+	if file, header, err := c.Request.FormFile("file"); err == nil {
+		defer file.Close()
+		body = file
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+	}
+	if format == "" {
+		switch {
+		case strings.Contains(c.ContentType(), "json"):
+			format = services.ImportFormatJSON
+		case strings.Contains(c.ContentType(), "csv"):
+			format = services.ImportFormatCSV
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := h.importService.Import(ctx, body, services.ImportOptions{
+		Format: format,
+		Replace: replace,
+		DryRun: dryRun,
+		Actor: c.GetString("user_address"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnsupportedFormat), errors.Is(err, services.ErrInvalidImportFile):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+		case errors.Is(err, services.ErrBatchTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": err.Error(),
+			})
+		default:
+			h.logger.WithError(err).Error("Failed to import whitelist")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to import whitelist",
+			})
+		}
+		return
+	}
+
+	status := http.StatusOK
+	if result.BatchJobID != 0 {
+		status = http.StatusAccepted
+	}
+	c.JSON(status, gin.H{
+		"success": true,
+		"data": result,
+	})
+}
+
+// ExportWhitelist downloads the whitelist as CSV, JSON or merkle tree input,
+// either as currently stored or as of ?block=N
+func (h *Handlers) ExportWhitelist(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", services.ExportFormatCSV))
+	if format != services.ExportFormatCSV && format != services.ExportFormatJSON && format != services.ExportFormatMerkle {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid format, expected csv, json or merkle",
+		})
+		return
+	}
+
+	var block *uint64
+	if raw := c.Query("block"); raw != "" {
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid block number",
+			})
+			return
+		}
+		block = &n
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	snapshot, err := h.exportService.Snapshot(ctx, block)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrBlockNotIndexed):
+			indexed, _ := h.exportService.IndexedBlock(ctx)
+			c.JSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+				"indexed_block": indexed,
+			})
+		case errors.Is(err, services.ErrSnapshotUnavailable):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+		default:
+			h.logger.WithError(err).Error("Failed to export whitelist")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to export whitelist",
+			})
+		}
+		return
+	}
+
+	filename := "whitelist"
+	if block != nil {
+		filename += "-" + strconv.FormatUint(*block, 10)
+	}
+	contentType, extension := "application/json", ".json"
+	switch format {
+	case services.ExportFormatCSV:
+		contentType, extension = "text/csv", ".csv"
+	case services.ExportFormatMerkle:
+		extension = ".merkle.json"
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+extension+`"`)
+	c.Status(http.StatusOK)
+	if err := services.WriteSnapshot(c.Writer, snapshot, format); err != nil {
+		h.logger.WithError(err).Error("Failed to write whitelist export")
+	}
+}
+
+func (h *Handlers) GetAllUsers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"message": "get all users endpoint"})
+}
+
+func (h *Handlers) RevokeUserSessions(c *gin.Context) {
+	address := c.Param("address")
+
+	if !common.IsHexAddress(address) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid Ethereum address format",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	checksummed := common.HexToAddress(address).Hex()
+	revoked, err := h.authService.RevokeAllSessions(ctx, checksummed)
+	if err != nil {
+		h.logger.WithError(err).WithField("address", checksummed).Error("Failed to revoke sessions")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revoke sessions",
+		})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"address": checksummed,
+		"revoked_by": c.GetString("user_address"),
+		"sessions": revoked,
+	}).Info("Sessions revoked by admin")
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"address": checksummed,
+			"revoked_sessions": revoked,
+		},
+	})
+}
+
+func (h *Handlers) ListRoles(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	roles, err := h.rbacService.ListRoles(ctx)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list roles")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list roles",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": roles,
+	})
+}
+
+func (h *Handlers) GetUserRoles(c *gin.Context) {
+	address, err := services.NormalizeAddress(c.Param("address"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid Ethereum address format",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	roles, err := h.rbacService.UserRoles(ctx, address)
+	if err != nil {
+		h.logger.WithError(err).WithField("address", address).Error("Failed to load user roles")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to load user roles",
+		})
+		return
+	}
+
+	permissions, err := h.rbacService.Permissions(ctx, address)
+	if err != nil {
+		h.logger.WithError(err).WithField("address", address).Error("Failed to load user permissions")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to load user permissions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"address": address,
+			"roles": roles,
+			"permissions": permissions,
+		},
+	})
+}
+
+func (h *Handlers) GrantRole(c *gin.Context) {
+	address, err := services.NormalizeAddress(c.Param("address"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid Ethereum address format",
+		})
+		return
+	}
+
+	var req struct {
+		Role string `json:"role" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.rbacService.GrantRole(ctx, address, req.Role, c.GetString("user_address")); err != nil {
+		h.respondRoleError(c, err, address, req.Role)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Role granted successfully",
+		"data": gin.H{
+			"address": address,
+			"role": req.Role,
+		},
+	})
+}
+
+func (h *Handlers) RevokeRole(c *gin.Context) {
+	address, err := services.NormalizeAddress(c.Param("address"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid Ethereum address format",
+		})
+		return
+	}
+	role := c.Param("role")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.rbacService.RevokeRole(ctx, address, role); err != nil {
+		h.respondRoleError(c, err, address, role)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Role revoked successfully",
+		"data": gin.H{
+			"address": address,
+			"role": role,
+		},
+	})
+}
+
+// respondRoleError maps role management failures to HTTP responses
+func (h *Handlers) respondRoleError(c *gin.Context, err error, address, role string) {
+	switch {
+	case errors.Is(err, services.ErrRoleNotFound), errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrRoleNotHeld):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrLastSuperAdmin):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		h.logger.WithError(err).WithFields(logrus.Fields{
+			"address": address,
+			"role": role,
+		}).Error("Failed to update roles")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update roles",
+		})
+	}
+}
+
+func (h *Handlers) CreateAPIKey(c *gin.Context) {
+	// Keys can only be minted by a signed-in wallet, never by another key
+	if c.GetString("auth_method") == "api_key" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "API keys cannot create other API keys",
+		})
+		return
+	}
+
+	var req struct {
+		Name          string   `json:"name" binding:"required"`
+		Scopes        []string `json:"scopes" binding:"required"`
+		ExpiresInDays int      `json:"expires_in_days" binding:"min=0"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	owner := c.GetString("user_address")
+	rawKey, key, err := h.apiKeyService.Create(ctx, owner, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidScope) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		h.logger.WithError(err).WithField("owner", owner).Error("Failed to create API key")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create API key",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Store this key now, it will not be shown again",
+		"data": gin.H{
+			"key": rawKey,
+			"api_key": services.APIKeyToDTO(key),
+		},
+	})
+}
+
+func (h *Handlers) ListAPIKeys(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	keys, err := h.apiKeyService.List(ctx)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list API keys")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list API keys",
+		})
+		return
+	}
+
+	dtos := make([]models.APIKeyDTO, 0, len(keys))
+	for i := range keys {
+		dtos = append(dtos, services.APIKeyToDTO(&keys[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": dtos,
+	})
+}
+
+func (h *Handlers) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid API key ID",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.apiKeyService.Revoke(ctx, uint(id), c.GetString("user_address")); err != nil {
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		h.logger.WithError(err).WithField("key_id", id).Error("Failed to revoke API key")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revoke API key",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "API key revoked successfully",
+	})
+}
+
+func (h *Handlers) UpdateSaleConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"message": "update sale config endpoint"})
+}
+
+func (h *Handlers) PauseSale(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	record, err := h.blockchainService.PauseSale(ctx)
+	if err != nil {
+		h.respondTxError(c, err, "Failed to pause sale")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": "Pause transaction sent",
+		"tx_id": record.ID,
+		"data": record,
+	})
+}
+
+func (h *Handlers) UnpauseSale(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	record, err := h.blockchainService.UnpauseSale(ctx)
+	if err != nil {
+		h.respondTxError(c, err, "Failed to unpause sale")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": "Unpause transaction sent",
+		"tx_id": record.ID,
+		"data": record,
+	})
+}
+
+// Transaction handlers
+// EstimateTransaction dry-runs a whitelist or sale transaction and returns
+// the gas limit and cost it would be sent with, and whether it would be
+// refused for exceeding the block gas limit or the cost ceiling
+func (h *Handlers) EstimateTransaction(c *gin.Context) {
+	var req struct {
+		Action    string   `json:"action" binding:"required"`
+		Addresses []string `json:"addresses"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	estimate, err := h.blockchainService.EstimateTransaction(ctx, req.Action, req.Addresses)
+	if err != nil && estimate == nil {
+		h.respondTxError(c, err, "Failed to estimate transaction")
+		return
+	}
+
+	data := gin.H{
+		"estimate": estimate,
+		"submittable": err == nil,
+	}
+	if err != nil {
+		data["reason"] = err.Error()
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": data,
+	})
+}
+
+func (h *Handlers) GetTransaction(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid transaction ID",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	record, err := h.blockchainService.GetTransaction(ctx, uint(id))
+	if err != nil {
+		h.respondTxError(c, err, "Failed to load transaction")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": record,
+	})
+}
+
+func (h *Handlers) respondTxError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAddress):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid Ethereum address format",
+		})
+	case errors.Is(err, services.ErrInvalidTxAction):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrTxNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrGasLimitExceeded), errors.Is(err, services.ErrTxCostExceeded),
+		errors.Is(err, services.ErrExecutionReverted), errors.Is(err, services.ErrFeeCapExceeded):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": message,
+			"details": err.Error(),
+		})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": message,
+		})
+	}
+}
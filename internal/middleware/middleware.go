@@ -1,9 +1,14 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
+	"slices"
 	"time"
 
+	"whitelist-token-backend/internal/models"
+	"whitelist-token-backend/internal/services"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -80,6 +85,7 @@ func CORS() gin.HandlerFunc {
 		"Authorization",
 		"Accept",
 		"X-Requested-With",
+		"X-API-Key",
 	}
 	config.AllowCredentials = true
 	config.MaxAge = 12 * time.Hour
@@ -95,9 +101,53 @@ func RateLimit() gin.HandlerFunc {
 	}
 }
 
+// APIKeyAuth middleware authenticates requests carrying an X-API-Key header.
+// Requests without the header fall through to AuthRequired.
+func APIKeyAuth(apiKeyService *services.APIKeyService, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rawKey := c.GetHeader("X-API-Key")
+		if rawKey == "" {
+			c.Next()
+			return
+		}
+
+		key, err := apiKeyService.Authenticate(c.Request.Context(), rawKey)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidAPIKey) {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "Invalid API key",
+				})
+			} else {
+				c.JSON(http.StatusServiceUnavailable, gin.H{
+					"error": "Unable to verify API key",
+				})
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set("user_address", key.User.Address)
+		c.Set("auth_method", "api_key")
+		c.Set("api_key", key)
+		c.Next()
+
+		err = apiKeyService.RecordUsage(c.Request.Context(), key, c.ClientIP(), c.Request.UserAgent(),
+			c.Request.Method, c.FullPath(), c.Writer.Status())
+		if err != nil {
+			logger.WithError(err).WithField("key_id", key.ID).Error("Failed to record API key usage")
+		}
+	}
+}
+
 // AuthRequired middleware
-func AuthRequired(jwtSecret string) gin.HandlerFunc {
+func AuthRequired(authService *services.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Already authenticated by APIKeyAuth
+		if c.GetString("auth_method") == "api_key" {
+			c.Next()
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{
@@ -119,34 +169,81 @@ func AuthRequired(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
-		// For demo purposes, accept our demo tokens
-		if len(tokenString) >= 16 && tokenString[:16] == "demo-admin-token" {
-			// Extract address from token (simplified for demo)
-			c.Set("user_address", "0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266")
-			c.Set("user_role", "admin")
-			c.Next()
+		claims, err := authService.ValidateAccessToken(tokenString)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid token",
+			})
+			c.Abort()
 			return
 		}
 
-		// In production, you would verify JWT token here
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Invalid token",
-		})
-		c.Abort()
+		// Check the revocation list so logged-out or revoked sessions stop working immediately
+		if err := authService.CheckSession(c.Request.Context(), claims); err != nil {
+			if errors.Is(err, services.ErrSessionRevoked) {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "Session revoked",
+				})
+			} else {
+				c.JSON(http.StatusServiceUnavailable, gin.H{
+					"error": "Unable to verify session",
+				})
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set("user_address", claims.Address)
+		c.Set("auth_method", "jwt")
+		c.Set("user_role", claims.Role)
+		c.Set("claims", claims)
+		c.Next()
 	}
 }
 
-// AdminRequired middleware
-func AdminRequired() gin.HandlerFunc {
+// RequirePermission middleware checks the authenticated user holds a permission.
+// API keys additionally need the permission among their scopes; the owner
+// must still hold it too, so revoking a role also narrows the owner's keys.
+func RequirePermission(rbacService *services.RBACService, permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userRole, exists := c.Get("user_role")
-		if !exists || userRole != "admin" {
+		address := c.GetString("user_address")
+		if address == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Authentication required",
+			})
+			c.Abort()
+			return
+		}
+
+		if value, ok := c.Get("api_key"); ok {
+			key := value.(*models.APIKey)
+			if !slices.Contains(services.KeyScopes(key), permission) {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error":    "API key is not scoped for this operation",
+					"required": permission,
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		allowed, err := rbacService.HasPermission(c.Request.Context(), address, permission)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": "Unable to verify permissions",
+			})
+			c.Abort()
+			return
+		}
+
+		if !allowed {
 			c.JSON(http.StatusForbidden, gin.H{
-				"error": "Admin access required",
+				"error":    "Insufficient permissions",
+				"required": permission,
 			})
 			c.Abort()
 			return
 		}
 		c.Next()
 	}
-}
\ No newline at end of file
+}
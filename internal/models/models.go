@@ -10,7 +10,7 @@ import (
 type User struct {
 	ID          uint           `json:"id" gorm:"primaryKey"`
 	Address     string         `json:"address" gorm:"uniqueIndex;not null"`
-	Nonce       string         `json:"nonce" gorm:"not null"`
+	Nonce       string         `json:"-" gorm:"not null"`
 	IsAdmin     bool           `json:"is_admin" gorm:"default:false"`
 	IsActive    bool           `json:"is_active" gorm:"default:true"`
 	LastLoginAt *time.Time     `json:"last_login_at"`
@@ -19,23 +19,133 @@ type User struct {
 	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 
 	// Relationships
+	UserRoles      []UserRole      `json:"user_roles,omitempty"`
 	WhitelistEntry *WhitelistEntry `json:"whitelist_entry,omitempty"`
 	Purchases      []Purchase      `json:"purchases,omitempty"`
 	ActivityLogs   []ActivityLog   `json:"activity_logs,omitempty"`
 }
 
+// Role represents a named set of permissions that can be granted to users
+type Role struct {
+	ID          uint         `json:"id" gorm:"primaryKey"`
+	Name        string       `json:"name" gorm:"uniqueIndex;not null"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions,omitempty" gorm:"many2many:role_permissions"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// Permission represents a single capability such as "whitelist:write"
+type Permission struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"uniqueIndex;not null"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Built-in role names, in ascending order of privilege
+const (
+	RoleUser              = "user"
+	RoleWhitelistOperator = "whitelist_operator"
+	RoleAdmin             = "admin"
+	RoleSuperAdmin        = "super_admin"
+)
+
+// Permission names checked by the API
+const (
+	PermWhitelistRead  = "whitelist:read"
+	PermWhitelistWrite = "whitelist:write"
+	PermSalePause      = "sale:pause"
+	PermSaleConfig     = "sale:config"
+	PermUsersRead      = "users:read"
+	PermUsersManage    = "users:manage"
+	PermRolesManage    = "roles:manage"
+	PermAPIKeysManage  = "api_keys:manage"
+)
+
+// PermissionDescriptions documents every permission seeded into the database
+var PermissionDescriptions = map[string]string{
+	PermWhitelistRead:  "View whitelist entries",
+	PermWhitelistWrite: "Add and remove whitelist entries",
+	PermSalePause:      "Pause and unpause the sale",
+	PermSaleConfig:     "Change the sale configuration",
+	PermUsersRead:      "View users and their roles",
+	PermUsersManage:    "Revoke user sessions",
+	PermRolesManage:    "Grant and revoke roles",
+	PermAPIKeysManage:  "Create and revoke API keys",
+}
+
+// DefaultRoles lists the built-in roles and the permissions each one carries
+var DefaultRoles = []struct {
+	Name        string
+	Description string
+	Permissions []string
+}{
+	{RoleUser, "Regular user with access to public endpoints", nil},
+	{RoleWhitelistOperator, "Manages the whitelist without control over the sale", []string{
+		PermWhitelistRead, PermWhitelistWrite,
+	}},
+	{RoleAdmin, "Manages the whitelist and the sale", []string{
+		PermWhitelistRead, PermWhitelistWrite, PermSalePause, PermSaleConfig, PermUsersRead, PermUsersManage,
+	}},
+	{RoleSuperAdmin, "Full access including role management", []string{
+		PermWhitelistRead, PermWhitelistWrite, PermSalePause, PermSaleConfig, PermUsersRead, PermUsersManage, PermRolesManage,
+		PermAPIKeysManage,
+	}},
+}
+
+// UserRole grants a role to a user
+type UserRole struct {
+	UserID    uint      `json:"user_id" gorm:"primaryKey"`
+	RoleID    uint      `json:"role_id" gorm:"primaryKey"`
+	GrantedBy string    `json:"granted_by"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relationships
+	User *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Role *Role `json:"role,omitempty" gorm:"foreignKey:RoleID"`
+}
+
+// APIKey represents a hashed, scoped credential for server-to-server calls
+type APIKey struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"not null"` // Owner the key acts on behalf of
+	Name       string     `json:"name" gorm:"not null"`
+	Prefix     string     `json:"prefix" gorm:"index;not null"` // Non-secret identifier shown in listings
+	KeyHash    string     `json:"-" gorm:"uniqueIndex;not null"`
+	Scopes     string     `json:"scopes" gorm:"type:text"` // Comma-separated permission names
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	RevokedBy  string     `json:"revoked_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	// Relationships
+	User *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
 // WhitelistEntry represents a whitelist entry
 type WhitelistEntry struct {
 	ID            uint           `json:"id" gorm:"primaryKey"`
 	UserID        uint           `json:"user_id" gorm:"not null"`
 	Address       string         `json:"address" gorm:"uniqueIndex;not null"`
 	IsWhitelisted bool           `json:"is_whitelisted" gorm:"default:false"`
+	WasWhitelisted bool          `json:"-"` // IsWhitelisted before the pending change, restored if its transaction fails
 	MaxAllocation string         `json:"max_allocation" gorm:"type:decimal(78,0)"` // Using string for big numbers
 	UsedAllocation string        `json:"used_allocation" gorm:"type:decimal(78,0);default:0"`
+	Tier          string         `json:"tier" gorm:"index"`
+	ValidFrom     *time.Time     `json:"valid_from"`  // Scheduled start of eligibility, nil if not time-boxed
+	ValidUntil    *time.Time     `json:"valid_until"` // Scheduled end of eligibility, nil if open-ended
+	Status        string         `json:"status" gorm:"default:'pending';index"` // pending, confirmed, failed
 	TxHash        string         `json:"tx_hash"`
+	TxID          uint           `json:"tx_id,omitempty" gorm:"index"` // Transaction record of the last single add or remove
 	BlockNumber   uint64         `json:"block_number"`
 	AddedBy       string         `json:"added_by"`
 	AddedAt       time.Time      `json:"added_at"`
+	RemovedBy     string         `json:"removed_by"`
+	RemovedAt     *time.Time     `json:"removed_at"`
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `json:"deleted_at" gorm:"index"`
@@ -44,6 +154,263 @@ type WhitelistEntry struct {
 	User *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
 
+// Whitelist entry statuses, tracking the transaction that last changed the entry
+const (
+	WhitelistStatusPending   = "pending"
+	WhitelistStatusConfirmed = "confirmed"
+	WhitelistStatusFailed    = "failed"
+	// WhitelistStatusScheduled marks an entry whose window has not opened yet
+	WhitelistStatusScheduled = "scheduled"
+)
+
+// WhitelistApplication is a signed request from a user to be whitelisted
+type WhitelistApplication struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	Address    string     `json:"address" gorm:"not null;index"`
+	Fields     string     `json:"fields" gorm:"type:text"` // JSON object of form fields
+	Message    string     `json:"message" gorm:"type:text"`
+	Signature  string     `json:"signature"`
+	Status     string     `json:"status" gorm:"default:'pending';index"` // pending, approving, approved, rejected
+	ReviewedBy string     `json:"reviewed_by"`
+	ReviewedAt *time.Time `json:"reviewed_at"`
+	ReviewNote string     `json:"review_note"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Whitelist application statuses
+const (
+	ApplicationStatusPending   = "pending"
+	ApplicationStatusApproving = "approving" // Claimed by a reviewer while the applicant is whitelisted
+	ApplicationStatusApproved  = "approved"
+	ApplicationStatusRejected  = "rejected"
+)
+
+// ScheduledChange is a whitelist add or removal to be submitted at ExecuteAt
+type ScheduledChange struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	Address    string     `json:"address" gorm:"not null;index"`
+	Action     string     `json:"action" gorm:"not null"` // add, remove
+	ExecuteAt  time.Time  `json:"execute_at" gorm:"not null;index"`
+	Status     string     `json:"status" gorm:"default:'scheduled';index"` // scheduled, submitted, cancelled, failed
+	BatchJobID *uint      `json:"batch_job_id"`
+	Error      string     `json:"error,omitempty"`
+	CreatedBy  string     `json:"created_by"`
+	ExecutedAt *time.Time `json:"executed_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Scheduled change statuses
+const (
+	ScheduleStatusScheduled = "scheduled"
+	ScheduleStatusSubmitted = "submitted"
+	ScheduleStatusCancelled = "cancelled"
+	ScheduleStatusFailed    = "failed"
+)
+
+// AllocationTier is a named allocation cap. Entries assigned to a tier
+// without an allocation of their own may buy up to the tier's cap.
+type AllocationTier struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"uniqueIndex;not null"`
+	Cap         string    `json:"cap" gorm:"type:decimal(78,0);not null"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WhitelistDrift records an address whose whitelist state in the database
+// disagrees with the token contract's whitelist mapping
+type WhitelistDrift struct {
+	ID               uint       `json:"id" gorm:"primaryKey"`
+	Address          string     `json:"address" gorm:"not null;index"`
+	DBWhitelisted    bool       `json:"db_whitelisted"`
+	ChainWhitelisted bool       `json:"chain_whitelisted"`
+	EntryStatus      string     `json:"entry_status"` // Status of the entry when the drift was detected
+	DetectedAt       time.Time  `json:"detected_at"`
+	LastSeenAt       time.Time  `json:"last_seen_at"`
+	ResolvedAt       *time.Time `json:"resolved_at"`
+	Resolution       string     `json:"resolution"` // in_sync, repaired
+	RepairTxHash     string     `json:"repair_tx_hash"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// Drift resolutions
+const (
+	DriftResolutionInSync   = "in_sync"
+	DriftResolutionRepaired = "repaired"
+)
+
+// MerkleRoot is a versioned merkle root built from the whitelist
+type MerkleRoot struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Version   uint      `json:"version" gorm:"uniqueIndex;not null"`
+	Root      string    `json:"root" gorm:"not null"`
+	LeafCount int       `json:"leaf_count"`
+	IsActive  bool      `json:"is_active" gorm:"default:false;index"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MerkleLeaf stores an address's leaf and proof for one merkle root version
+type MerkleLeaf struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	RootID     uint   `json:"root_id" gorm:"not null;uniqueIndex:idx_merkle_leaf_root_address"`
+	Address    string `json:"address" gorm:"not null;uniqueIndex:idx_merkle_leaf_root_address"`
+	Allocation string `json:"allocation" gorm:"type:decimal(78,0);not null"`
+	LeafHash   string `json:"leaf_hash" gorm:"not null"`
+	Proof      string `json:"proof" gorm:"type:text"` // JSON array of sibling hashes
+}
+
+// BatchJob is a large whitelist update processed in chunks in the background
+type BatchJob struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	Status          string     `json:"status" gorm:"default:'queued';index"` // queued, running, completed, completed_with_errors, failed
+	TotalOperations int        `json:"total_operations"`
+	TotalChunks     int        `json:"total_chunks"`
+	CreatedBy       string     `json:"created_by"`
+	Error           string     `json:"error,omitempty"`
+	StartedAt       *time.Time `json:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	// Relationships
+	Chunks []BatchChunk `json:"chunks,omitempty" gorm:"foreignKey:JobID"`
+}
+
+// BatchChunk is one updateWhitelistBatch call of a batch job
+type BatchChunk struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	JobID           uint       `json:"job_id" gorm:"not null;index"`
+	Position        int        `json:"position"`
+	Action          string     `json:"action"`             // add, remove
+	Changes         string     `json:"-" gorm:"type:text"` // JSON array of address/allocation pairs
+	AddressCount    int        `json:"address_count"`
+	Status          string     `json:"status" gorm:"default:'queued';index"` // queued, submitted, confirmed, partially_failed, failed
+	TxHashes        string     `json:"tx_hashes" gorm:"type:text"`           // Comma-separated, more than one when the chunk was split after a revert
+	BlockNumber     uint64     `json:"block_number"`
+	FailedAddresses string     `json:"failed_addresses" gorm:"type:text"` // Comma-separated
+	PendingTxID     uint       `json:"pending_tx_id,omitempty"`           // Transaction not yet seen mined
+	PendingChanges  string     `json:"-" gorm:"type:text"`                // JSON array of the changes it carries
+	Error           string     `json:"error,omitempty"`
+	Attempts        int        `json:"attempts"`
+	SubmittedAt     *time.Time `json:"submitted_at"`
+	CompletedAt     *time.Time `json:"completed_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Batch job and chunk statuses
+const (
+	BatchStatusQueued              = "queued"
+	BatchStatusRunning             = "running"
+	BatchStatusCompleted           = "completed"
+	BatchStatusCompletedWithErrors = "completed_with_errors"
+	BatchStatusFailed              = "failed"
+
+	ChunkStatusQueued          = "queued"
+	ChunkStatusSubmitted       = "submitted"
+	ChunkStatusConfirmed       = "confirmed"
+	ChunkStatusPartiallyFailed = "partially_failed"
+	ChunkStatusFailed          = "failed"
+)
+
+// Transaction is a transaction sent from the signer key. The signed
+// transaction is kept so it can be rebroadcast unchanged if a node drops it.
+type Transaction struct {
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	FromAddress       string     `json:"from_address" gorm:"not null;index:idx_transaction_nonce"`
+	Nonce             uint64     `json:"nonce" gorm:"not null;index:idx_transaction_nonce"`
+	ToAddress         string     `json:"to_address" gorm:"not null"`
+	Method            string     `json:"method"`
+	Params            string     `json:"params" gorm:"type:text"` // JSON array of the call arguments
+	TxHash            string     `json:"tx_hash" gorm:"index"`
+	ReplacedHashes    string     `json:"replaced_hashes" gorm:"type:text"` // Comma-separated hashes of earlier versions replaced with higher fees
+	RawTx             string     `json:"-" gorm:"type:text"`               // Hex-encoded signed transaction
+	GasLimit          uint64     `json:"gas_limit"`
+	GasTipCap         string     `json:"gas_tip_cap" gorm:"type:decimal(78,0)"` // Equal to GasFeeCap, the gas price, for legacy transactions
+	GasFeeCap         string     `json:"gas_fee_cap" gorm:"type:decimal(78,0)"`
+	SentBlock         uint64     `json:"sent_block"`                            // Head block when the current version was sent
+	Status            string     `json:"status" gorm:"default:'pending';index"` // pending, mined, confirmed, reverted, failed, dropped
+	Attempts          int        `json:"attempts"`                              // Times the transaction was broadcast
+	BlockNumber       uint64     `json:"block_number"`
+	BlockHash         string     `json:"block_hash,omitempty"`
+	GasUsed           uint64     `json:"gas_used"`
+	EffectiveGasPrice string     `json:"effective_gas_price" gorm:"type:decimal(78,0);default:0"`
+	Error             string     `json:"error,omitempty"`
+	RevertReason      string     `json:"revert_reason,omitempty" gorm:"type:text"` // Decoded by replaying a reverted transaction
+	MinedAt           *time.Time `json:"mined_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// Transaction statuses. Mined transactions are waiting for enough blocks on
+// top of them to be confirmed or reverted. Failed transactions were never
+// accepted by the node and did not use their nonce; dropped ones lost their
+// nonce to another transaction.
+const (
+	TxStatusPending   = "pending"
+	TxStatusMined     = "mined"
+	TxStatusConfirmed = "confirmed"
+	TxStatusReverted  = "reverted"
+	TxStatusFailed    = "failed"
+	TxStatusDropped   = "dropped"
+)
+
+// SignerNonce is the next nonce to assign to a signer's transactions
+type SignerNonce struct {
+	Address   string    `json:"address" gorm:"primaryKey"`
+	NextNonce uint64    `json:"next_nonce"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WhitelistEvent is a WhitelistUpdated event emitted by the token contract
+type WhitelistEvent struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Address     string    `json:"address" gorm:"not null;index"`
+	Whitelisted bool      `json:"whitelisted"`
+	BlockNumber uint64    `json:"block_number" gorm:"not null;index"`
+	TxHash      string    `json:"tx_hash" gorm:"not null;uniqueIndex:idx_whitelist_event_log"`
+	LogIndex    uint      `json:"log_index" gorm:"not null;uniqueIndex:idx_whitelist_event_log"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ChainEvent is a decoded event emitted by the sale or token contract, or a
+// raw log that failed to decode. The unique log key makes re-indexing a block
+// range a no-op.
+type ChainEvent struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Contract    string    `json:"contract" gorm:"not null"`
+	Name        string    `json:"name" gorm:"not null;index"`
+	Data        string    `json:"data" gorm:"type:text"`                   // JSON object of event arguments, or the raw log when DecodeError is set
+	DecodeError string    `json:"decode_error,omitempty" gorm:"type:text"` // Why the log could not be decoded
+	BlockNumber uint64    `json:"block_number" gorm:"not null;index"`
+	BlockHash   string    `json:"block_hash"`
+	TxHash      string    `json:"tx_hash" gorm:"not null;uniqueIndex:idx_chain_event_log"`
+	LogIndex    uint      `json:"log_index" gorm:"not null;uniqueIndex:idx_chain_event_log"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// IndexedBlock is the hash of a recently indexed block, kept to detect
+// chain reorganizations
+type IndexedBlock struct {
+	Number     uint64    `json:"number" gorm:"primaryKey;autoIncrement:false"`
+	Hash       string    `json:"hash" gorm:"not null"`
+	ParentHash string    `json:"parent_hash" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// IndexerCursor records the last block an event indexer has processed
+type IndexerCursor struct {
+	Name        string    `json:"name" gorm:"primaryKey"`
+	BlockNumber uint64    `json:"block_number"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
 // Purchase represents a token purchase
 type Purchase struct {
 	ID              uint           `json:"id" gorm:"primaryKey"`
@@ -52,7 +419,8 @@ type Purchase struct {
 	TokenAmount     string         `json:"token_amount" gorm:"type:decimal(78,0);not null"`
 	EthAmount       string         `json:"eth_amount" gorm:"type:decimal(78,0);not null"`
 	TokenPrice      string         `json:"token_price" gorm:"type:decimal(78,0);not null"`
-	TxHash          string         `json:"tx_hash" gorm:"uniqueIndex;not null"`
+	TxHash          string         `json:"tx_hash" gorm:"not null;uniqueIndex:idx_purchase_log"`
+	LogIndex        uint           `json:"log_index" gorm:"not null;uniqueIndex:idx_purchase_log"`
 	BlockNumber     uint64         `json:"block_number" gorm:"not null"`
 	BlockTimestamp  time.Time      `json:"block_timestamp" gorm:"not null"`
 	Status          string         `json:"status" gorm:"default:'pending'"`          // pending, confirmed, failed
@@ -67,6 +435,13 @@ type Purchase struct {
 	User *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
 
+// Purchase statuses
+const (
+	PurchaseStatusPending   = "pending"
+	PurchaseStatusConfirmed = "confirmed"
+	PurchaseStatusFailed    = "failed"
+)
+
 // SaleConfig represents the token sale configuration
 type SaleConfig struct {
 	ID                uint      `json:"id" gorm:"primaryKey"`
@@ -141,6 +516,20 @@ type UserDTO struct {
 	CreatedAt   time.Time  `json:"created_at"`
 }
 
+// APIKeyDTO represents API key data transfer object; the secret is never included
+type APIKeyDTO struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Owner      string     `json:"owner"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
 // WhitelistStatusDTO represents whitelist status response
 type WhitelistStatusDTO struct {
 	Address        string    `json:"address"`
@@ -148,6 +537,7 @@ type WhitelistStatusDTO struct {
 	MaxAllocation  string    `json:"max_allocation"`
 	UsedAllocation string    `json:"used_allocation"`
 	RemainingAllocation string `json:"remaining_allocation"`
+	Tier           string    `json:"tier,omitempty"`
 	AddedAt        time.Time `json:"added_at,omitempty"`
 }
 
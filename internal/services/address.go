@@ -0,0 +1,28 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrInvalidAddress is returned for malformed addresses or bad EIP-55 checksums
+var ErrInvalidAddress = errors.New("invalid Ethereum address")
+
+// NormalizeAddress validates an address and returns its EIP-55 checksummed form.
+// All-lowercase and all-uppercase addresses carry no checksum and are accepted;
+// mixed-case addresses must match their checksum exactly.
+func NormalizeAddress(address string) (string, error) {
+	if !strings.HasPrefix(address, "0x") || !common.IsHexAddress(address) {
+		return "", ErrInvalidAddress
+	}
+
+	checksummed := common.HexToAddress(address).Hex()
+	hexPart := address[2:]
+	if hexPart != strings.ToLower(hexPart) && hexPart != strings.ToUpper(hexPart) && address != checksummed {
+		return "", ErrInvalidAddress
+	}
+
+	return checksummed, nil
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"whitelist-token-backend/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// API key errors returned to handlers and middleware
+var (
+	ErrInvalidAPIKey  = errors.New("invalid, expired or revoked API key")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrInvalidScope   = errors.New("invalid API key scope")
+)
+
+const (
+	apiKeyPrefix       = "wlk_"
+	apiKeyPrefixLength = len(apiKeyPrefix) + 8
+)
+
+// APIKeyService manages scoped API keys for server-to-server integrations
+type APIKeyService struct {
+	db     *gorm.DB
+	rbac   *RBACService
+	logger *logrus.Logger
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(db *gorm.DB, rbac *RBACService, logger *logrus.Logger) *APIKeyService {
+	return &APIKeyService{
+		db:     db,
+		rbac:   rbac,
+		logger: logger,
+	}
+}
+
+// Create issues a new key owned by ownerAddress. Scopes must be permissions the
+// owner currently holds, so a key can never grant more than its creator has.
+// The plaintext key is only returned here; the database keeps its hash.
+func (ks *APIKeyService) Create(ctx context.Context, ownerAddress, name string, scopes []string, expiresAt *time.Time) (string, *models.APIKey, error) {
+	if len(scopes) == 0 {
+		return "", nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+
+	ownerPermissions, err := ks.rbac.Permissions(ctx, ownerAddress)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, scope := range scopes {
+		if _, known := models.PermissionDescriptions[scope]; !known {
+			return "", nil, fmt.Errorf("%w: unknown permission %q", ErrInvalidScope, scope)
+		}
+		if !slices.Contains(ownerPermissions, scope) {
+			return "", nil, fmt.Errorf("%w: you do not hold %q", ErrInvalidScope, scope)
+		}
+	}
+
+	var owner models.User
+	if err := ks.db.WithContext(ctx).Where("address = ?", ownerAddress).First(&owner).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to load key owner: %w", err)
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	rawKey := apiKeyPrefix + hex.EncodeToString(secret)
+
+	key := &models.APIKey{
+		UserID:    owner.ID,
+		Name:      name,
+		Prefix:    rawKey[:apiKeyPrefixLength],
+		KeyHash:   hashToken(rawKey),
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: expiresAt,
+	}
+	if err := ks.db.WithContext(ctx).Create(key).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to store API key: %w", err)
+	}
+	key.User = &owner
+
+	ks.logger.WithFields(logrus.Fields{
+		"key_id": key.ID,
+		"prefix": key.Prefix,
+		"owner":  ownerAddress,
+		"scopes": key.Scopes,
+	}).Info("API key created")
+	return rawKey, key, nil
+}
+
+// Authenticate resolves a plaintext key to an active API key
+func (ks *APIKeyService) Authenticate(ctx context.Context, rawKey string) (*models.APIKey, error) {
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) || len(rawKey) <= apiKeyPrefixLength {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var key models.APIKey
+	err := ks.db.WithContext(ctx).Preload("User").Where("key_hash = ?", hashToken(rawKey)).First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, fmt.Errorf("failed to load API key: %w", err)
+	}
+
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+	if key.User == nil || !key.User.IsActive {
+		return nil, ErrInvalidAPIKey
+	}
+
+	return &key, nil
+}
+
+// List returns all API keys, newest first
+func (ks *APIKeyService) List(ctx context.Context) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := ks.db.WithContext(ctx).Preload("User").Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Revoke permanently disables a key
+func (ks *APIKeyService) Revoke(ctx context.Context, id uint, revokedBy string) error {
+	now := time.Now()
+	result := ks.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"revoked_at": now, "revoked_by": revokedBy})
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke API key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	ks.logger.WithFields(logrus.Fields{
+		"key_id":     id,
+		"revoked_by": revokedBy,
+	}).Info("API key revoked")
+	return nil
+}
+
+// RecordUsage updates last-used tracking and writes an activity log entry for a call made with a key
+func (ks *APIKeyService) RecordUsage(ctx context.Context, key *models.APIKey, ip, userAgent, method, path string, status int) error {
+	details, err := json.Marshal(map[string]interface{}{
+		"api_key_id": key.ID,
+		"prefix":     key.Prefix,
+		"name":       key.Name,
+		"method":     method,
+		"path":       path,
+		"status":     status,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode activity details: %w", err)
+	}
+
+	now := time.Now()
+	return ks.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.APIKey{}).Where("id = ?", key.ID).
+			Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip}).Error
+		if err != nil {
+			return fmt.Errorf("failed to update API key usage: %w", err)
+		}
+
+		activity := models.ActivityLog{
+			UserID:    key.UserID,
+			Address:   key.User.Address,
+			Action:    "api_key_request",
+			Details:   string(details),
+			IPAddress: ip,
+			UserAgent: userAgent,
+		}
+		if err := tx.Create(&activity).Error; err != nil {
+			return fmt.Errorf("failed to write activity log: %w", err)
+		}
+		return nil
+	})
+}
+
+// APIKeyToDTO converts a key to its API representation
+func APIKeyToDTO(key *models.APIKey) models.APIKeyDTO {
+	dto := models.APIKeyDTO{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     KeyScopes(key),
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		LastUsedIP: key.LastUsedIP,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+	if key.User != nil {
+		dto.Owner = key.User.Address
+	}
+	return dto
+}
+
+// KeyScopes returns the permission names granted to a key
+func KeyScopes(key *models.APIKey) []string {
+	if key.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(key.Scopes, ",")
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"whitelist-token-backend/internal/models"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Application errors returned to handlers
+var (
+	ErrApplicationNotFound  = errors.New("whitelist application not found")
+	ErrApplicationPending   = errors.New("a whitelist application for this address is already pending")
+	ErrApplicationReviewed  = errors.New("whitelist application has already been reviewed")
+	ErrAlreadyWhitelisted   = errors.New("address is already whitelisted")
+	ErrInvalidApplication   = errors.New("invalid application")
+	ErrApplicationNonceUsed = errors.New("application nonce is invalid, expired or already used")
+)
+
+const (
+	applicationNonceKeyPrefix = "whitelist:apply:nonce:"
+	maxApplicationFields      = 20
+	maxFieldNameLength        = 64
+	maxFieldValueLength       = 1000
+)
+
+// ApplicationConfig holds the settings used to build application messages
+type ApplicationConfig struct {
+	Domain   string
+	NonceTTL time.Duration
+}
+
+// ApplicationMessage is the text a wallet signs to submit an application
+type ApplicationMessage struct {
+	Message   string    `json:"message"`
+	Nonce     string    `json:"nonce"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ApplicationFilter narrows the applications returned by List
+type ApplicationFilter struct {
+	Status   string
+	Page     int
+	PageSize int
+}
+
+// ApplicationService handles self-service whitelist applications. Users sign
+// their form fields with their wallet; admins approve or reject them.
+type ApplicationService struct {
+	db               *gorm.DB
+	redis            *redis.Client
+	authService      *AuthService
+	whitelistService *WhitelistService
+	config           ApplicationConfig
+	logger           *logrus.Logger
+}
+
+// NewApplicationService creates a new application service
+func NewApplicationService(
+	db *gorm.DB,
+	redis *redis.Client,
+	authService *AuthService,
+	whitelistService *WhitelistService,
+	config ApplicationConfig,
+	logger *logrus.Logger,
+) *ApplicationService {
+	if config.NonceTTL <= 0 {
+		config.NonceTTL = 10 * time.Minute
+	}
+	return &ApplicationService{
+		db:               db,
+		redis:            redis,
+		authService:      authService,
+		whitelistService: whitelistService,
+		config:           config,
+		logger:           logger,
+	}
+}
+
+// PrepareMessage issues a single-use nonce for an address and returns the
+// message covering the given fields that the wallet must sign
+func (as *ApplicationService) PrepareMessage(ctx context.Context, address string, fields map[string]string) (*ApplicationMessage, error) {
+	address, err := NormalizeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateFields(fields); err != nil {
+		return nil, err
+	}
+
+	nonce, err := generateNonce()
+	if err != nil {
+		return nil, err
+	}
+	if err := as.redis.Set(ctx, applicationNonceKeyPrefix+address, nonce, as.config.NonceTTL).Err(); err != nil {
+		return nil, fmt.Errorf("failed to store application nonce: %w", err)
+	}
+
+	return &ApplicationMessage{
+		Message:   as.buildMessage(address, fields, nonce),
+		Nonce:     nonce,
+		ExpiresAt: time.Now().Add(as.config.NonceTTL),
+	}, nil
+}
+
+// Submit verifies the signature over the fields and nonce and stores the
+// application as pending. The nonce is consumed even if verification fails.
+func (as *ApplicationService) Submit(ctx context.Context, address string, fields map[string]string, nonce, signature, ip string) (*models.WhitelistApplication, error) {
+	address, err := NormalizeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateFields(fields); err != nil {
+		return nil, err
+	}
+
+	stored, err := as.redis.GetDel(ctx, applicationNonceKeyPrefix+address).Result()
+	if errors.Is(err, redis.Nil) || (err == nil && stored != nonce) {
+		return nil, ErrApplicationNonceUsed
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load application nonce: %w", err)
+	}
+
+	message := as.buildMessage(address, fields, nonce)
+	valid, err := as.authService.VerifySignature(ctx, address, message, signature)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, ErrInvalidSignature
+	}
+
+	var entry models.WhitelistEntry
+	err = as.db.WithContext(ctx).Where("address = ? AND is_whitelisted = ?", address, true).First(&entry).Error
+	if err == nil {
+		return nil, ErrAlreadyWhitelisted
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to load whitelist entry: %w", err)
+	}
+
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode application fields: %w", err)
+	}
+	application := models.WhitelistApplication{
+		Address:   address,
+		Fields:    string(encoded),
+		Message:   message,
+		Signature: signature,
+		Status:    models.ApplicationStatusPending,
+		IPAddress: ip,
+	}
+
+	err = as.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var pending int64
+		err := tx.Model(&models.WhitelistApplication{}).
+			Where("address = ? AND status IN ?", address, []string{models.ApplicationStatusPending, models.ApplicationStatusApproving}).
+			Count(&pending).Error
+		if err != nil {
+			return fmt.Errorf("failed to check pending applications: %w", err)
+		}
+		if pending > 0 {
+			return ErrApplicationPending
+		}
+		if err := tx.Create(&application).Error; err != nil {
+			return fmt.Errorf("failed to store application: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	as.logger.WithField("address", address).WithField("application_id", application.ID).Info("Whitelist application submitted")
+	return &application, nil
+}
+
+// List returns a page of applications, oldest first so the review queue is worked in order
+func (as *ApplicationService) List(ctx context.Context, filter ApplicationFilter) ([]models.WhitelistApplication, int64, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 || filter.PageSize > 500 {
+		filter.PageSize = 50
+	}
+
+	query := as.db.WithContext(ctx).Model(&models.WhitelistApplication{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count applications: %w", err)
+	}
+
+	var applications []models.WhitelistApplication
+	err := query.Order("created_at, id").
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&applications).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list applications: %w", err)
+	}
+	return applications, total, nil
+}
+
+// Approve whitelists the applicant through the whitelist service and marks
+// the application approved. The application is claimed first so that two
+// reviewers cannot both whitelist it; if the whitelist transaction cannot be
+// sent the claim is released and the application can be approved again.
+func (as *ApplicationService) Approve(ctx context.Context, id uint, maxAllocation, tier, reviewer, note string) (*models.WhitelistApplication, *models.WhitelistEntry, error) {
+	application, err := as.pending(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tier != "" {
+		if err := as.whitelistService.checkTier(ctx, strings.ToLower(strings.TrimSpace(tier))); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := as.review(ctx, application, models.ApplicationStatusPending, models.ApplicationStatusApproving, reviewer, note); err != nil {
+		return nil, nil, err
+	}
+
+	entry, err := as.whitelistService.Add(ctx, application.Address, maxAllocation, reviewer)
+	if err != nil {
+		bgCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), receiptTimeout)
+		defer cancel()
+		releaseErr := as.db.WithContext(bgCtx).Model(&models.WhitelistApplication{}).
+			Where("id = ? AND status = ?", application.ID, models.ApplicationStatusApproving).
+			Updates(map[string]interface{}{
+				"status":      models.ApplicationStatusPending,
+				"reviewed_by": "",
+				"reviewed_at": nil,
+				"review_note": "",
+			}).Error
+		if releaseErr != nil {
+			as.logger.WithError(releaseErr).WithField("application_id", application.ID).Error("Failed to release application claim")
+		}
+		return nil, entry, err
+	}
+
+	if err := as.review(ctx, application, models.ApplicationStatusApproving, models.ApplicationStatusApproved, reviewer, note); err != nil {
+		return nil, entry, err
+	}
+	if tier != "" {
+		if entry, err = as.whitelistService.AssignTier(ctx, application.Address, tier); err != nil {
+			return nil, nil, err
+		}
+	}
+	return application, entry, nil
+}
+
+// Reject marks a pending application as rejected
+func (as *ApplicationService) Reject(ctx context.Context, id uint, reviewer, note string) (*models.WhitelistApplication, error) {
+	application, err := as.pending(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := as.review(ctx, application, models.ApplicationStatusPending, models.ApplicationStatusRejected, reviewer, note); err != nil {
+		return nil, err
+	}
+	return application, nil
+}
+
+func (as *ApplicationService) pending(ctx context.Context, id uint) (*models.WhitelistApplication, error) {
+	var application models.WhitelistApplication
+	if err := as.db.WithContext(ctx).First(&application, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrApplicationNotFound
+		}
+		return nil, fmt.Errorf("failed to load application: %w", err)
+	}
+	if application.Status != models.ApplicationStatusPending {
+		return nil, ErrApplicationReviewed
+	}
+	return &application, nil
+}
+
+// review moves the application from one status to another and records the
+// reviewer. The status condition stops two admins from reviewing the same
+// application concurrently.
+func (as *ApplicationService) review(ctx context.Context, application *models.WhitelistApplication, from, status, reviewer, note string) error {
+	now := time.Now()
+	result := as.db.WithContext(ctx).Model(&models.WhitelistApplication{}).
+		Where("id = ? AND status = ?", application.ID, from).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"reviewed_by": reviewer,
+			"reviewed_at": now,
+			"review_note": note,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update application: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrApplicationReviewed
+	}
+
+	application.Status = status
+	application.ReviewedBy = reviewer
+	application.ReviewedAt = &now
+	application.ReviewNote = note
+
+	as.logger.WithFields(logrus.Fields{
+		"application_id": application.ID,
+		"address":        application.Address,
+		"status":         status,
+		"reviewer":       reviewer,
+	}).Info("Whitelist application reviewed")
+	return nil
+}
+
+// buildMessage renders the signed text. Fields are sorted by name so the
+// client and server produce the same message.
+func (as *ApplicationService) buildMessage(address string, fields map[string]string, nonce string) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s whitelist application\n\n", as.config.Domain)
+	fmt.Fprintf(&b, "Address: %s\n", address)
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\n", name, fields[name])
+	}
+	fmt.Fprintf(&b, "\nNonce: %s", nonce)
+	return b.String()
+}
+
+func validateFields(fields map[string]string) error {
+	if len(fields) > maxApplicationFields {
+		return fmt.Errorf("%w: at most %d fields are allowed", ErrInvalidApplication, maxApplicationFields)
+	}
+	for name, value := range fields {
+		if name == "" || len(name) > maxFieldNameLength || strings.ContainsAny(name, ":\n\r") {
+			return fmt.Errorf("%w: invalid field name %q", ErrInvalidApplication, name)
+		}
+		if len(value) > maxFieldValueLength || strings.ContainsAny(value, "\n\r") {
+			return fmt.Errorf("%w: invalid value for field %q", ErrInvalidApplication, name)
+		}
+	}
+	return nil
+}
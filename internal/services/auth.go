@@ -1,19 +1,313 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"whitelist-token-backend/internal/models"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Authentication errors returned to handlers
+var (
+	ErrInvalidMessage   = errors.New("invalid sign-in message")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrInvalidNonce     = errors.New("invalid or already used nonce")
+	ErrMessageExpired   = errors.New("sign-in message expired")
+
+	ErrTooManyNonceRequests = errors.New("too many nonce requests, try again later")
 )
 
+const (
+	// clockSkew is the tolerance applied to client-supplied timestamps
+	clockSkew = time.Minute
+	// nonceRateWindow is the window NonceRateLimit applies to
+	nonceRateWindow = time.Minute
+)
+
+func authNonceKey(nonce string) string { return "auth:nonce:" + nonce }
+func nonceRateKey(ip string) string    { return "auth:nonce-rate:" + ip }
+
+// AuthConfig holds the settings used to build and verify sign-in messages and tokens
+type AuthConfig struct {
+	Domain    string
+	URI       string
+	Statement string
+	ChainID   int64
+	NonceTTL  time.Duration
+	// NonceRateLimit is the number of nonces a client IP may request per minute; 0 disables the limit
+	NonceRateLimit int
+
+	SigningMethod   string
+	PrivateKeyPath  string
+	PublicKeyPath   string
+	Issuer          string
+	Audience        string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
 // AuthService handles authentication operations
 type AuthService struct {
-	jwtSecret string
-	logger    *logrus.Logger
+	db         *gorm.DB
+	redis      *redis.Client
+	rbac       *RBACService
+	blockchain *BlockchainService
+	keys       *tokenKeys
+	config     AuthConfig
+	logger     *logrus.Logger
 }
 
 // NewAuthService creates a new auth service
-func NewAuthService(jwtSecret string, logger *logrus.Logger) *AuthService {
+func NewAuthService(db *gorm.DB, redis *redis.Client, rbac *RBACService, blockchain *BlockchainService, jwtSecret string, config AuthConfig, logger *logrus.Logger) (*AuthService, error) {
+	keys, err := loadTokenKeys(config.SigningMethod, jwtSecret, config.PrivateKeyPath, config.PublicKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load JWT keys: %w", err)
+	}
+
 	return &AuthService{
-		jwtSecret: jwtSecret,
-		logger:    logger,
+		db:         db,
+		redis:      redis,
+		rbac:       rbac,
+		blockchain: blockchain,
+		keys:       keys,
+		config:     config,
+		logger:     logger,
+	}, nil
+}
+
+// IssueNonce issues a single-use nonce and returns the message the wallet
+// should sign. Nonces live in Redis until they expire or are used, so several
+// sign-ins for the same address can be in flight and no user is stored until
+// a signature has been verified. Each client IP may request at most
+// NonceRateLimit nonces per minute.
+func (as *AuthService) IssueNonce(ctx context.Context, address, ip string) (*SIWEMessage, error) {
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("invalid address")
+	}
+	checksummed := common.HexToAddress(address).Hex()
+
+	if err := as.limitNonceRequests(ctx, ip); err != nil {
+		return nil, err
+	}
+
+	nonce, err := generateNonce()
+	if err != nil {
+		return nil, err
+	}
+	if err := as.redis.Set(ctx, authNonceKey(nonce), checksummed, as.config.NonceTTL).Err(); err != nil {
+		return nil, fmt.Errorf("failed to store nonce: %w", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	expiresAt := now.Add(as.config.NonceTTL)
+	return &SIWEMessage{
+		Domain:         as.config.Domain,
+		Address:        checksummed,
+		Statement:      as.config.Statement,
+		URI:            as.config.URI,
+		Version:        siweVersion,
+		ChainID:        as.config.ChainID,
+		Nonce:          nonce,
+		IssuedAt:       now,
+		ExpirationTime: &expiresAt,
+	}, nil
+}
+
+// limitNonceRequests counts nonce requests per client IP in fixed one-minute windows
+func (as *AuthService) limitNonceRequests(ctx context.Context, ip string) error {
+	if as.config.NonceRateLimit <= 0 {
+		return nil
+	}
+
+	key := nonceRateKey(ip)
+	count, err := as.redis.Incr(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("failed to count nonce requests: %w", err)
+	}
+	if count == 1 {
+		if err := as.redis.Expire(ctx, key, nonceRateWindow).Err(); err != nil {
+			return fmt.Errorf("failed to count nonce requests: %w", err)
+		}
+	}
+	if count > int64(as.config.NonceRateLimit) {
+		return ErrTooManyNonceRequests
+	}
+	return nil
+}
+
+// Login verifies a signed EIP-4361 message and consumes its nonce. The user
+// is created on their first successful sign-in.
+func (as *AuthService) Login(ctx context.Context, address, message, signature string) (*models.User, error) {
+	msg, err := ParseSIWEMessage(message)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+
+	if err := as.validateMessage(msg, address); err != nil {
+		return nil, err
+	}
+	checksummed := common.HexToAddress(msg.Address).Hex()
+
+	// The nonce is consumed even if the signature turns out to be invalid, so
+	// a signed message can be used at most once, also by concurrent logins.
+	issuedTo, err := as.redis.GetDel(ctx, authNonceKey(msg.Nonce)).Result()
+	if errors.Is(err, redis.Nil) || (err == nil && issuedTo != checksummed) {
+		return nil, ErrInvalidNonce
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load nonce: %w", err)
+	}
+
+	valid, err := as.VerifySignature(ctx, msg.Address, message, signature)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, ErrInvalidSignature
+	}
+
+	user, err := findOrCreateUser(as.db.WithContext(ctx), checksummed)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if err := as.db.WithContext(ctx).Model(user).Update("last_login_at", now).Error; err != nil {
+		return nil, fmt.Errorf("failed to record login: %w", err)
 	}
-}
\ No newline at end of file
+
+	as.logger.WithField("address", user.Address).Info("User signed in")
+	return user, nil
+}
+
+// VerifySignature checks that signature is a personal_sign signature of message by address.
+// Smart-contract wallets such as Safe cannot be ecrecovered, so for addresses
+// with code the wallet itself is asked to validate the signature (EIP-1271).
+func (as *AuthService) VerifySignature(ctx context.Context, address, message, signature string) (bool, error) {
+	if !common.IsHexAddress(address) {
+		return false, fmt.Errorf("%w: invalid address", ErrInvalidSignature)
+	}
+
+	sig, err := hexutil.Decode(signature)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	hash := accounts.TextHash([]byte(message))
+
+	if as.blockchain != nil {
+		isContract, err := as.blockchain.IsContract(ctx, address)
+		if err != nil {
+			return false, err
+		}
+		if isContract {
+			return as.blockchain.IsValidSignature(ctx, address, common.BytesToHash(hash), sig)
+		}
+	}
+
+	signer, err := recoverSigner(hash, sig)
+	if err != nil {
+		return false, err
+	}
+
+	return signer == common.HexToAddress(address), nil
+}
+
+// validateMessage checks the message fields against our configuration and the clock
+func (as *AuthService) validateMessage(msg *SIWEMessage, address string) error {
+	if !strings.EqualFold(msg.Address, address) {
+		return fmt.Errorf("%w: address mismatch", ErrInvalidMessage)
+	}
+	if msg.Domain != as.config.Domain {
+		return fmt.Errorf("%w: unexpected domain %q", ErrInvalidMessage, msg.Domain)
+	}
+	if msg.ChainID != as.config.ChainID {
+		return fmt.Errorf("%w: unexpected chain ID %d", ErrInvalidMessage, msg.ChainID)
+	}
+
+	now := time.Now()
+	if msg.IssuedAt.After(now.Add(clockSkew)) {
+		return fmt.Errorf("%w: issued in the future", ErrInvalidMessage)
+	}
+	if now.Sub(msg.IssuedAt) > as.config.NonceTTL+clockSkew {
+		return ErrMessageExpired
+	}
+	if msg.ExpirationTime != nil && now.After(*msg.ExpirationTime) {
+		return ErrMessageExpired
+	}
+	if msg.NotBefore != nil && now.Add(clockSkew).Before(*msg.NotBefore) {
+		return fmt.Errorf("%w: message not yet valid", ErrInvalidMessage)
+	}
+
+	return nil
+}
+
+// recoverSigner recovers the address that produced a 65-byte [R || S || V] signature over hash
+func recoverSigner(hash []byte, sig []byte) (common.Address, error) {
+	if len(sig) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidSignature, crypto.SignatureLength, len(sig))
+	}
+
+	// Wallets produce V as 27/28 while go-ethereum expects 0/1
+	normalized := make([]byte, len(sig))
+	copy(normalized, sig)
+	if normalized[crypto.RecoveryIDOffset] >= 27 {
+		normalized[crypto.RecoveryIDOffset] -= 27
+	}
+
+	pubKey, err := crypto.SigToPub(hash, normalized)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	return crypto.PubkeyToAddress(*pubKey), nil
+}
+
+// findOrCreateUser loads the user for a checksummed address, creating it on first sight
+func findOrCreateUser(db *gorm.DB, address string) (*models.User, error) {
+	var user models.User
+	err := db.Where("address = ?", address).First(&user).Error
+	if err == nil {
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+
+	nonce, err := generateNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	user = models.User{
+		Address:  address,
+		Nonce:    nonce,
+		IsActive: true,
+	}
+	// Another request may create the user concurrently. Skipping the insert
+	// instead of failing it keeps an enclosing transaction usable in Postgres.
+	result := db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "address"}}, DoNothing: true}).Create(&user)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to create user: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		user = models.User{}
+		if err := db.Where("address = ?", address).First(&user).Error; err != nil {
+			return nil, fmt.Errorf("failed to load user: %w", err)
+		}
+	}
+
+	return &user, nil
+}
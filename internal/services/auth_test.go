@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// returnWordCode is runtime code that ignores its input and returns word
+func returnWordCode(word [32]byte) []byte {
+	code := append([]byte{0x7f}, word[:]...)                            // PUSH32 word
+	return append(code, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3) // MSTORE at 0, RETURN 32 bytes
+}
+
+// revertCode is runtime code that reverts without data
+var revertCode = []byte{0x60, 0x00, 0x60, 0x00, 0xfd}
+
+// deployCode deploys runtime code with an init code that copies it into memory and returns it
+func deployCode(t *testing.T, sim *backends.SimulatedBackend, auth *bind.TransactOpts, runtime []byte) common.Address {
+	t.Helper()
+	initCode := []byte{0x60, byte(len(runtime)), 0x80, 0x60, 0x0b, 0x60, 0x00, 0x39, 0x60, 0x00, 0xf3}
+	address, _, _, err := bind.DeployContract(auth, abi.ABI{}, append(initCode, runtime...), sim)
+	if err != nil {
+		t.Fatalf("deploy: %v", err)
+	}
+	sim.Commit()
+	return address
+}
+
+func TestVerifySignatureEIP1271(t *testing.T) {
+	deployer, _ := crypto.GenerateKey()
+	auth, err := bind.NewKeyedTransactorWithChainID(deployer, big.NewInt(1337))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim := backends.NewSimulatedBackend(core.GenesisAlloc{
+		auth.From: {Balance: new(big.Int).Lsh(big.NewInt(1), 100)},
+	}, 30_000_000)
+	defer sim.Close()
+
+	erc1271ABI, err := abi.JSON(strings.NewReader(ERC1271ABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	as := &AuthService{blockchain: &BlockchainService{caller: sim, erc1271ABI: erc1271ABI}}
+
+	var magic, wrong [32]byte
+	copy(magic[:], erc1271MagicValue[:])
+	copy(wrong[:], []byte{0xff, 0xff, 0xff, 0xff})
+	magicWallet := deployCode(t, sim, auth, returnWordCode(magic))
+	wrongWallet := deployCode(t, sim, auth, returnWordCode(wrong))
+	revertingWallet := deployCode(t, sim, auth, revertCode)
+
+	const message = "localhost wants you to sign in with your Ethereum account"
+	owner, _ := crypto.GenerateKey()
+	ownerSig, err := crypto.Sign(accounts.TextHash([]byte(message)), owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownerSig[crypto.RecoveryIDOffset] += 27
+	other, _ := crypto.GenerateKey()
+
+	tests := []struct {
+		name      string
+		address   common.Address
+		signature []byte
+		want      bool
+	}{
+		{"contract returns magic value", magicWallet, []byte{0x01}, true},
+		{"contract returns wrong value", wrongWallet, []byte{0x01}, false},
+		{"contract reverts", revertingWallet, []byte{0x01}, false},
+		{"EOA signature", crypto.PubkeyToAddress(owner.PublicKey), ownerSig, true},
+		{"EOA signature by another key", crypto.PubkeyToAddress(other.PublicKey), ownerSig, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := as.VerifySignature(context.Background(), tt.address.Hex(), message, hexutil.Encode(tt.signature))
+			if err != nil {
+				t.Fatalf("VerifySignature: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("VerifySignature = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
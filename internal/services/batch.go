@@ -0,0 +1,689 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"whitelist-token-backend/internal/models"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Batch errors returned to handlers
+var (
+	ErrBatchJobNotFound = errors.New("batch job not found")
+	ErrBatchEmpty       = errors.New("batch contains no valid operations")
+	ErrBatchTooLarge    = errors.New("batch exceeds the maximum number of operations")
+)
+
+const (
+	batchActionAdd    = "add"
+	batchActionRemove = "remove"
+
+	batchLockKey = "lock:whitelist:batch"
+	// batchLockTTL must outlast the submission and mining of a single chunk
+	batchLockTTL      = 5 * time.Minute
+	batchChunkTimeout = 4 * time.Minute
+	// batchBookkeepingTimeout bounds storing a chunk's outcome after its deadline
+	batchBookkeepingTimeout = 30 * time.Second
+	batchPollInterval       = 15 * time.Second
+)
+
+// BatchConfig controls how batch jobs are split and limited
+type BatchConfig struct {
+	ChunkSize     int
+	MaxOperations int
+}
+
+// BatchOperation is a single add or remove requested in a batch
+type BatchOperation struct {
+	Address       string `json:"address"`
+	Action        string `json:"action"`
+	MaxAllocation string `json:"max_allocation,omitempty"`
+	Tier          string `json:"tier,omitempty"`
+}
+
+// RejectedOperation explains why an operation was left out of a batch
+type RejectedOperation struct {
+	Index   int    `json:"index"`
+	Address string `json:"address"`
+	Reason  string `json:"reason"`
+}
+
+// BatchValidation reports what happened to the submitted operations
+type BatchValidation struct {
+	Accepted   int                 `json:"accepted"`
+	Duplicates int                 `json:"duplicates"`
+	Rejected   []RejectedOperation `json:"rejected"`
+}
+
+// BatchProgress summarises chunk statuses for polling clients
+type BatchProgress struct {
+	Queued          int `json:"queued"`
+	Submitted       int `json:"submitted"`
+	Confirmed       int `json:"confirmed"`
+	PartiallyFailed int `json:"partially_failed"`
+	Failed          int `json:"failed"`
+	FailedAddresses int `json:"failed_addresses"`
+}
+
+// BatchService splits large whitelist updates into chunks and submits them
+// one after another, so transactions from the same signer never race on nonces
+type BatchService struct {
+	db                *gorm.DB
+	redis             *redis.Client
+	blockchainService *BlockchainService
+	whitelistService  *WhitelistService
+	config            BatchConfig
+	wake              chan struct{}
+	logger            *logrus.Logger
+}
+
+// NewBatchService creates a new batch service
+func NewBatchService(
+	db *gorm.DB,
+	redis *redis.Client,
+	blockchainService *BlockchainService,
+	whitelistService *WhitelistService,
+	config BatchConfig,
+	logger *logrus.Logger,
+) *BatchService {
+	if config.ChunkSize <= 0 {
+		config.ChunkSize = 200
+	}
+	if config.MaxOperations <= 0 {
+		config.MaxOperations = 50000
+	}
+	return &BatchService{
+		db:                db,
+		redis:             redis,
+		blockchainService: blockchainService,
+		whitelistService:  whitelistService,
+		config:            config,
+		wake:              make(chan struct{}, 1),
+		logger:            logger,
+	}
+}
+
+// Submit validates and deduplicates operations and queues them as a job.
+// Invalid operations are reported and skipped rather than failing the batch.
+func (bs *BatchService) Submit(ctx context.Context, operations []BatchOperation, createdBy string) (*models.BatchJob, *BatchValidation, error) {
+	if len(operations) > bs.config.MaxOperations {
+		return nil, nil, fmt.Errorf("%w (%d > %d)", ErrBatchTooLarge, len(operations), bs.config.MaxOperations)
+	}
+
+	validation := &BatchValidation{Rejected: []RejectedOperation{}}
+	seen := make(map[string]int) // address -> index into accepted
+	var accepted []BatchOperation
+
+	for i, op := range operations {
+		reject := func(reason string) {
+			validation.Rejected = append(validation.Rejected, RejectedOperation{Index: i, Address: op.Address, Reason: reason})
+		}
+
+		action := strings.ToLower(strings.TrimSpace(op.Action))
+		if action != batchActionAdd && action != batchActionRemove {
+			reject(fmt.Sprintf("unknown action %q", op.Action))
+			continue
+		}
+		address, err := NormalizeAddress(strings.TrimSpace(op.Address))
+		if err != nil {
+			reject(err.Error())
+			continue
+		}
+		allocation := ""
+		if action == batchActionAdd && op.MaxAllocation != "" {
+			if allocation, err = parseAllocation(op.MaxAllocation); err != nil {
+				reject(err.Error())
+				continue
+			}
+		}
+
+		if prev, ok := seen[address]; ok {
+			if accepted[prev].Action != action {
+				reject("conflicting add and remove for the same address")
+				continue
+			}
+			validation.Duplicates++
+			continue
+		}
+
+		seen[address] = len(accepted)
+		accepted = append(accepted, BatchOperation{Address: address, Action: action, MaxAllocation: allocation, Tier: strings.TrimSpace(op.Tier)})
+	}
+
+	if len(accepted) == 0 {
+		return nil, validation, ErrBatchEmpty
+	}
+	validation.Accepted = len(accepted)
+
+	chunks, err := bs.buildChunks(accepted)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	job := &models.BatchJob{
+		Status:          models.BatchStatusQueued,
+		TotalOperations: len(accepted),
+		TotalChunks:     len(chunks),
+		CreatedBy:       createdBy,
+		Chunks:          chunks,
+	}
+	if err := bs.db.WithContext(ctx).Create(job).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to create batch job: %w", err)
+	}
+
+	bs.logger.WithFields(logrus.Fields{
+		"job_id":     job.ID,
+		"operations": job.TotalOperations,
+		"chunks":     job.TotalChunks,
+		"created_by": createdBy,
+	}).Info("Batch job queued")
+
+	select {
+	case bs.wake <- struct{}{}:
+	default:
+	}
+
+	return job, validation, nil
+}
+
+// GetJob returns a job with its chunks and a progress summary
+func (bs *BatchService) GetJob(ctx context.Context, id uint) (*models.BatchJob, *BatchProgress, error) {
+	var job models.BatchJob
+	err := bs.db.WithContext(ctx).
+		Preload("Chunks", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		First(&job, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrBatchJobNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to load batch job: %w", err)
+	}
+
+	progress := &BatchProgress{}
+	for _, chunk := range job.Chunks {
+		switch chunk.Status {
+		case models.ChunkStatusQueued:
+			progress.Queued++
+		case models.ChunkStatusSubmitted:
+			progress.Submitted++
+		case models.ChunkStatusConfirmed:
+			progress.Confirmed++
+		case models.ChunkStatusPartiallyFailed:
+			progress.PartiallyFailed++
+		case models.ChunkStatusFailed:
+			progress.Failed++
+		}
+		if chunk.FailedAddresses != "" {
+			progress.FailedAddresses += len(strings.Split(chunk.FailedAddresses, ","))
+		}
+	}
+
+	return &job, progress, nil
+}
+
+// ListJobs returns the most recent batch jobs without their chunks
+func (bs *BatchService) ListJobs(ctx context.Context, limit int) ([]models.BatchJob, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	var jobs []models.BatchJob
+	if err := bs.db.WithContext(ctx).Order("id DESC").Limit(limit).Find(&jobs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list batch jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// Start processes queued jobs until ctx is cancelled. Jobs interrupted by a
+// restart are picked up again, since their progress is kept per chunk.
+func (bs *BatchService) Start(ctx context.Context) {
+	ticker := time.NewTicker(batchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for bs.processNextChunk(ctx) {
+			if ctx.Err() != nil {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-bs.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// processNextChunk handles one chunk of the oldest unfinished job and reports
+// whether there may be more work. The Redis lock keeps other replicas from
+// sending transactions from the same signer at the same time.
+func (bs *BatchService) processNextChunk(ctx context.Context) bool {
+	release, err := acquireLock(ctx, bs.redis, batchLockKey, batchLockTTL)
+	if err != nil {
+		if !errors.Is(err, ErrLockHeld) && ctx.Err() == nil {
+			bs.logger.WithError(err).Error("Failed to acquire batch lock")
+		}
+		return false
+	}
+	defer release()
+
+	var job models.BatchJob
+	err = bs.db.WithContext(ctx).
+		Where("status IN ?", []string{models.BatchStatusQueued, models.BatchStatusRunning}).
+		Order("id").
+		First(&job).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			bs.logger.WithError(err).Error("Failed to load batch job")
+		}
+		return false
+	}
+
+	if job.Status == models.BatchStatusQueued {
+		now := time.Now()
+		job.Status = models.BatchStatusRunning
+		job.StartedAt = &now
+		if err := bs.db.WithContext(ctx).Save(&job).Error; err != nil {
+			bs.logger.WithError(err).WithField("job_id", job.ID).Error("Failed to start batch job")
+			return false
+		}
+	}
+
+	var chunk models.BatchChunk
+	err = bs.db.WithContext(ctx).
+		Where("job_id = ? AND status IN ?", job.ID, []string{models.ChunkStatusQueued, models.ChunkStatusSubmitted}).
+		Order("position").
+		First(&chunk).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		bs.finishJob(ctx, &job)
+		return true
+	}
+	if err != nil {
+		bs.logger.WithError(err).WithField("job_id", job.ID).Error("Failed to load batch chunk")
+		return false
+	}
+
+	chunkCtx, cancel := context.WithTimeout(ctx, batchChunkTimeout)
+	defer cancel()
+	if err := bs.processChunk(chunkCtx, &job, &chunk); err != nil {
+		bs.logger.WithError(err).WithFields(logrus.Fields{
+			"job_id": job.ID,
+			"chunk":  chunk.Position,
+		}).Error("Failed to process batch chunk")
+		return false
+	}
+	return true
+}
+
+func (bs *BatchService) processChunk(ctx context.Context, job *models.BatchJob, chunk *models.BatchChunk) error {
+	var changes []WhitelistChange
+	if err := json.Unmarshal([]byte(chunk.Changes), &changes); err != nil {
+		return bs.completeChunk(ctx, job, chunk, nil, nil, 0, fmt.Errorf("corrupt chunk: %w", err))
+	}
+	add := chunk.Action == batchActionAdd
+
+	// Merkle mode has no on-chain mapping to update
+	if bs.whitelistService.Mode() == WhitelistModeMerkle {
+		if err := bs.whitelistService.RecordBatchChange(ctx, changes, add, job.CreatedBy, models.WhitelistStatusConfirmed, "", 0); err != nil {
+			return err
+		}
+		return bs.completeChunk(ctx, job, chunk, nil, nil, 0, nil)
+	}
+
+	result := &chunkResult{}
+	if chunk.TxHashes != "" {
+		result.txHashes = strings.Split(chunk.TxHashes, ",")
+	}
+
+	// A chunk left in submitted state may have a transaction in flight. Wait
+	// for it before sending anything else; whitelist updates are idempotent,
+	// so whatever it turns out not to cover is simply resent.
+	if chunk.Status == models.ChunkStatusSubmitted && chunk.PendingTxID != 0 {
+		remaining, err := bs.resumePending(ctx, job, chunk, changes, add, result)
+		if err != nil {
+			return err
+		}
+		changes = remaining
+	}
+
+	if len(changes) > 0 {
+		now := time.Now()
+		chunk.Status = models.ChunkStatusSubmitted
+		chunk.Attempts++
+		chunk.SubmittedAt = &now
+		if err := bs.db.WithContext(ctx).Save(chunk).Error; err != nil {
+			return fmt.Errorf("failed to update batch chunk: %w", err)
+		}
+
+		bs.submit(ctx, job, chunk, changes, add, result)
+	}
+
+	// The chunk deadline may have passed; the outcome must still be stored
+	bgCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), batchBookkeepingTimeout)
+	defer cancel()
+
+	if len(result.failed) > 0 {
+		if err := bs.whitelistService.RecordBatchChange(bgCtx, result.failed, add, job.CreatedBy, models.WhitelistStatusFailed, "", 0); err != nil {
+			bs.logger.WithError(err).WithField("job_id", job.ID).Error("Failed to record failed batch addresses")
+		}
+	}
+
+	var failed []string
+	if chunk.FailedAddresses != "" {
+		failed = strings.Split(chunk.FailedAddresses, ",")
+	}
+	for _, change := range result.failed {
+		failed = append(failed, change.Address)
+	}
+
+	if len(result.unsettled) > 0 {
+		return bs.suspendChunk(bgCtx, job, chunk, result, failed)
+	}
+	return bs.completeChunk(bgCtx, job, chunk, result.txHashes, failed, result.blockNumber, result.err)
+}
+
+// resumePending waits for the transaction a chunk was left waiting on and
+// returns the changes that still have to be sent
+func (bs *BatchService) resumePending(ctx context.Context, job *models.BatchJob, chunk *models.BatchChunk, changes []WhitelistChange, add bool, result *chunkResult) ([]WhitelistChange, error) {
+	var pending []WhitelistChange
+	if err := json.Unmarshal([]byte(chunk.PendingChanges), &pending); err != nil {
+		return changes, nil
+	}
+
+	record, err := bs.blockchainService.GetTransaction(ctx, chunk.PendingTxID)
+	if errors.Is(err, ErrTxNotFound) {
+		return changes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record.Status == models.TxStatusDropped || record.Status == models.TxStatusFailed {
+		return changes, nil
+	}
+
+	receipt, err := bs.blockchainService.WaitMined(ctx, record)
+	if receipt == nil {
+		return nil, fmt.Errorf("transaction %s still unresolved: %w", record.TxHash, err)
+	}
+	if err != nil {
+		// Reverted: the changes are sent again and split as usual
+		return changes, nil
+	}
+
+	blockNumber := receipt.BlockNumber.Uint64()
+	if blockNumber > result.blockNumber {
+		result.blockNumber = blockNumber
+	}
+	if err := bs.whitelistService.RecordBatchChange(ctx, pending, add, job.CreatedBy, models.WhitelistStatusConfirmed, record.TxHash, blockNumber); err != nil {
+		return nil, err
+	}
+
+	done := make(map[string]bool, len(pending))
+	for _, change := range pending {
+		done[change.Address] = true
+	}
+	var remaining []WhitelistChange
+	for _, change := range changes {
+		if !done[change.Address] {
+			remaining = append(remaining, change)
+		}
+	}
+	return remaining, nil
+}
+
+// chunkResult accumulates the outcome of submitting a chunk, possibly split
+type chunkResult struct {
+	txHashes    []string
+	failed      []WhitelistChange
+	blockNumber uint64
+	err         error
+
+	// unsettled holds changes whose outcome is unknown: those in a
+	// transaction that could not be seen mined, and everything after it
+	unsettled      []WhitelistChange
+	pendingTx      *models.Transaction
+	pendingChanges []WhitelistChange
+}
+
+// submit sends changes in one transaction. When it reverts, or needs more gas
+// than a block holds, the changes are split in half and retried so one bad
+// address does not fail the whole chunk.
+func (bs *BatchService) submit(ctx context.Context, job *models.BatchJob, chunk *models.BatchChunk, changes []WhitelistChange, add bool, result *chunkResult) {
+	// Once a transaction is unresolved nothing else is sent, so the next
+	// attempt knows which one to wait for
+	if len(result.unsettled) > 0 || ctx.Err() != nil {
+		result.unsettled = append(result.unsettled, changes...)
+		if result.err == nil {
+			result.err = ctx.Err()
+		}
+		return
+	}
+
+	addresses := make([]string, len(changes))
+	for i, change := range changes {
+		addresses[i] = change.Address
+	}
+
+	var record *models.Transaction
+	var err error
+	if add {
+		record, err = bs.blockchainService.AddToWhitelist(ctx, addresses)
+	} else {
+		record, err = bs.blockchainService.RemoveFromWhitelist(ctx, addresses)
+	}
+
+	if err != nil {
+		switch {
+		case ctx.Err() != nil:
+			// Cut off by the deadline rather than refused
+			result.unsettled = append(result.unsettled, changes...)
+		case errors.Is(err, ErrGasLimitExceeded) && len(changes) > 1:
+			// Too much gas for one block: split in half
+			mid := len(changes) / 2
+			bs.submit(ctx, job, chunk, changes[:mid], add, result)
+			bs.submit(ctx, job, chunk, changes[mid:], add, result)
+			return
+		default:
+			result.failed = append(result.failed, changes...)
+		}
+		result.err = err
+		return
+	}
+
+	// Record the transaction right away so a restart waits for it instead
+	// of sending the changes again
+	result.txHashes = append(result.txHashes, record.TxHash)
+	bs.recordPending(ctx, job, chunk, result.txHashes, record, changes)
+
+	receipt, err := bs.blockchainService.WaitMined(ctx, record)
+	// The mined version may be a replacement with higher fees
+	result.txHashes[len(result.txHashes)-1] = record.TxHash
+
+	if receipt == nil {
+		// Not seen mined: still pending, dropped or unknown. The next
+		// attempt checks the transaction again before resending anything.
+		result.unsettled = append(result.unsettled, changes...)
+		result.pendingTx = record
+		result.pendingChanges = changes
+		result.err = err
+		return
+	}
+
+	blockNumber := receipt.BlockNumber.Uint64()
+	if err == nil {
+		if blockNumber > result.blockNumber {
+			result.blockNumber = blockNumber
+		}
+		bgCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), batchBookkeepingTimeout)
+		defer cancel()
+		if err := bs.whitelistService.RecordBatchChange(bgCtx, changes, add, job.CreatedBy, models.WhitelistStatusConfirmed, record.TxHash, blockNumber); err != nil {
+			result.err = err
+		}
+		return
+	}
+
+	// Mined but reverted: bisect to isolate the offending addresses
+	if len(changes) > 1 {
+		mid := len(changes) / 2
+		bs.submit(ctx, job, chunk, changes[:mid], add, result)
+		bs.submit(ctx, job, chunk, changes[mid:], add, result)
+		return
+	}
+
+	result.failed = append(result.failed, changes...)
+	result.err = err
+}
+
+// recordPending stores the transaction a chunk is waiting on
+func (bs *BatchService) recordPending(ctx context.Context, job *models.BatchJob, chunk *models.BatchChunk, txHashes []string, record *models.Transaction, changes []WhitelistChange) {
+	encoded, err := json.Marshal(changes)
+	if err == nil {
+		err = bs.db.WithContext(ctx).Model(chunk).Updates(map[string]interface{}{
+			"tx_hashes":       strings.Join(txHashes, ","),
+			"pending_tx_id":   record.ID,
+			"pending_changes": string(encoded),
+		}).Error
+	}
+	if err != nil {
+		bs.logger.WithError(err).WithFields(logrus.Fields{
+			"job_id":  job.ID,
+			"chunk":   chunk.Position,
+			"tx_hash": record.TxHash,
+		}).Error("Failed to record pending batch transaction")
+	}
+}
+
+// suspendChunk leaves a chunk submitted with only its unsettled changes, so
+// the next attempt resumes where this one stopped
+func (bs *BatchService) suspendChunk(ctx context.Context, job *models.BatchJob, chunk *models.BatchChunk, result *chunkResult, failed []string) error {
+	encoded, err := json.Marshal(result.unsettled)
+	if err != nil {
+		return fmt.Errorf("failed to encode batch chunk: %w", err)
+	}
+	chunk.Changes = string(encoded)
+	chunk.PendingTxID = 0
+	chunk.PendingChanges = ""
+	if result.pendingTx != nil {
+		pending, err := json.Marshal(result.pendingChanges)
+		if err != nil {
+			return fmt.Errorf("failed to encode batch chunk: %w", err)
+		}
+		chunk.PendingTxID = result.pendingTx.ID
+		chunk.PendingChanges = string(pending)
+	}
+	chunk.TxHashes = strings.Join(result.txHashes, ",")
+	chunk.FailedAddresses = strings.Join(failed, ",")
+	chunk.Error = ""
+	if result.err != nil {
+		chunk.Error = result.err.Error()
+	}
+
+	if err := bs.db.WithContext(ctx).Save(chunk).Error; err != nil {
+		return fmt.Errorf("failed to update batch chunk: %w", err)
+	}
+	return fmt.Errorf("%d changes of chunk %d of job %d left unresolved: %v", len(result.unsettled), chunk.Position, job.ID, result.err)
+}
+
+// completeChunk stores the final state of a chunk
+func (bs *BatchService) completeChunk(ctx context.Context, job *models.BatchJob, chunk *models.BatchChunk, txHashes, failed []string, blockNumber uint64, chunkErr error) error {
+	now := time.Now()
+	switch {
+	case len(failed) == 0 && chunkErr == nil:
+		chunk.Status = models.ChunkStatusConfirmed
+	case len(failed) < chunk.AddressCount:
+		chunk.Status = models.ChunkStatusPartiallyFailed
+	default:
+		chunk.Status = models.ChunkStatusFailed
+	}
+	if len(txHashes) > 0 {
+		chunk.TxHashes = strings.Join(txHashes, ",")
+	}
+	chunk.BlockNumber = blockNumber
+	chunk.FailedAddresses = strings.Join(failed, ",")
+	chunk.PendingTxID = 0
+	chunk.PendingChanges = ""
+	chunk.CompletedAt = &now
+	chunk.Error = ""
+	if chunkErr != nil {
+		chunk.Error = chunkErr.Error()
+	}
+
+	if err := bs.db.WithContext(ctx).Save(chunk).Error; err != nil {
+		return fmt.Errorf("failed to update batch chunk: %w", err)
+	}
+
+	bs.logger.WithFields(logrus.Fields{
+		"job_id":    job.ID,
+		"chunk":     chunk.Position,
+		"status":    chunk.Status,
+		"tx_hashes": chunk.TxHashes,
+		"failed":    len(failed),
+	}).Info("Batch chunk processed")
+	return nil
+}
+
+// finishJob marks a job whose chunks are all processed as done
+func (bs *BatchService) finishJob(ctx context.Context, job *models.BatchJob) {
+	var unsuccessful int64
+	err := bs.db.WithContext(ctx).Model(&models.BatchChunk{}).
+		Where("job_id = ? AND status <> ?", job.ID, models.ChunkStatusConfirmed).
+		Count(&unsuccessful).Error
+	if err != nil {
+		bs.logger.WithError(err).WithField("job_id", job.ID).Error("Failed to count batch chunks")
+		return
+	}
+
+	now := time.Now()
+	job.FinishedAt = &now
+	switch {
+	case unsuccessful == 0:
+		job.Status = models.BatchStatusCompleted
+	case int(unsuccessful) == job.TotalChunks:
+		job.Status = models.BatchStatusFailed
+	default:
+		job.Status = models.BatchStatusCompletedWithErrors
+	}
+
+	if err := bs.db.WithContext(ctx).Save(job).Error; err != nil {
+		bs.logger.WithError(err).WithField("job_id", job.ID).Error("Failed to finish batch job")
+		return
+	}
+	bs.logger.WithField("job_id", job.ID).WithField("status", job.Status).Info("Batch job finished")
+}
+
+// buildChunks groups operations by action and splits them into chunks
+func (bs *BatchService) buildChunks(operations []BatchOperation) ([]models.BatchChunk, error) {
+	byAction := map[string][]WhitelistChange{}
+	for _, op := range operations {
+		byAction[op.Action] = append(byAction[op.Action], WhitelistChange{Address: op.Address, MaxAllocation: op.MaxAllocation, Tier: op.Tier})
+	}
+
+	var chunks []models.BatchChunk
+	for _, action := range []string{batchActionAdd, batchActionRemove} {
+		changes := byAction[action]
+		for start := 0; start < len(changes); start += bs.config.ChunkSize {
+			end := min(start+bs.config.ChunkSize, len(changes))
+			encoded, err := json.Marshal(changes[start:end])
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode batch chunk: %w", err)
+			}
+			chunks = append(chunks, models.BatchChunk{
+				Position:     len(chunks),
+				Action:       action,
+				Changes:      string(encoded),
+				AddressCount: end - start,
+				Status:       models.ChunkStatusQueued,
+			})
+		}
+	}
+	return chunks, nil
+}
@@ -3,11 +3,14 @@ package services
 import (
 	"context"
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"math/big"
 	"strings"
 	"time"
 
+	"whitelist-token-backend/internal/models"
+
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -15,17 +18,24 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/sirupsen/logrus"
 )
 
 // BlockchainService handles blockchain interactions
 type BlockchainService struct {
 	client          *ethclient.Client
+	caller          bind.ContractCaller // Code lookups and wallet signature checks; the client outside tests
+	wsClient        *ethclient.Client // Optional websocket connection for subscriptions
 	contractAddress common.Address
 	tokenAddress    common.Address
+	multicallAddress common.Address
 	privateKey      *ecdsa.PrivateKey
 	saleABI         abi.ABI
 	tokenABI        abi.ABI
+	erc1271ABI      abi.ABI
+	multicallABI    abi.ABI
+	txManager       *TxManager // Sends every write transaction
 	logger          *logrus.Logger
 }
 
@@ -64,12 +74,25 @@ func NewBlockchainService(rpcURL, contractAddr, tokenAddr string) (*BlockchainSe
 		return nil, fmt.Errorf("failed to parse token ABI: %w", err)
 	}
 
+	erc1271ABI, err := abi.JSON(strings.NewReader(ERC1271ABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ERC-1271 ABI: %w", err)
+	}
+
+	multicallABI, err := abi.JSON(strings.NewReader(Multicall3ABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Multicall3 ABI: %w", err)
+	}
+
 	return &BlockchainService{
 		client:          client,
+		caller:          client,
 		contractAddress: contractAddress,
 		tokenAddress:    tokenAddress,
 		saleABI:         saleABI,
 		tokenABI:        tokenABI,
+		erc1271ABI:      erc1271ABI,
+		multicallABI:    multicallABI,
 		logger:          logrus.New(),
 	}, nil
 }
@@ -89,6 +112,86 @@ func (bs *BlockchainService) SetPrivateKey(privateKeyHex string) error {
 	return nil
 }
 
+// SetMulticallAddress sets the Multicall3 contract used to batch read calls
+func (bs *BlockchainService) SetMulticallAddress(address string) {
+	if address == "" {
+		bs.multicallAddress = common.Address{}
+		return
+	}
+	bs.multicallAddress = common.HexToAddress(address)
+}
+
+// SetTxManager sets the transaction manager every write is sent through
+func (bs *BlockchainService) SetTxManager(txManager *TxManager) {
+	bs.txManager = txManager
+}
+
+// SetWebsocketURL connects to a websocket RPC endpoint used for subscriptions.
+// Without one, subscriptions go through the main RPC client, which only
+// supports them when it is a websocket or IPC connection.
+func (bs *BlockchainService) SetWebsocketURL(wsURL string) error {
+	if wsURL == "" {
+		return nil
+	}
+
+	client, err := ethclient.Dial(wsURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to websocket endpoint: %w", err)
+	}
+
+	bs.wsClient = client
+	return nil
+}
+
+// ChainID returns the chain ID of the connected network
+func (bs *BlockchainService) ChainID(ctx context.Context) (*big.Int, error) {
+	chainID, err := bs.client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+	return chainID, nil
+}
+
+// TransactionReceipt returns the receipt of a mined transaction, or nil if it is not mined yet
+func (bs *BlockchainService) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	receipt, err := bs.client.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		if errors.Is(err, ethereum.NotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get transaction receipt: %w", err)
+	}
+	return receipt, nil
+}
+
+// IsContract reports whether an address has contract code deployed
+func (bs *BlockchainService) IsContract(ctx context.Context, address string) (bool, error) {
+	code, err := bs.caller.CodeAt(ctx, common.HexToAddress(address), nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to get code: %w", err)
+	}
+	return len(code) > 0, nil
+}
+
+// IsValidSignature asks a smart-contract wallet whether signature is valid for hash (EIP-1271).
+// A revert is treated as an invalid signature, since wallets such as Safe revert on mismatch.
+func (bs *BlockchainService) IsValidSignature(ctx context.Context, wallet string, hash [32]byte, signature []byte) (bool, error) {
+	callOpts := &bind.CallOpts{Context: ctx}
+
+	contract := bind.NewBoundContract(common.HexToAddress(wallet), bs.erc1271ABI, bs.caller, nil, nil)
+	var result []interface{}
+	err := contract.Call(callOpts, &result, "isValidSignature", hash, signature)
+	if err != nil {
+		if isExecutionReverted(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to call isValidSignature: %w", err)
+	}
+
+	magic := result[0].([4]byte)
+	return magic == erc1271MagicValue, nil
+}
+
 // GetSaleInfo retrieves current sale information from the smart contract
 func (bs *BlockchainService) GetSaleInfo(ctx context.Context) (*SaleInfo, error) {
 	if bs.contractAddress == (common.Address{}) {
@@ -177,7 +280,7 @@ func (bs *BlockchainService) IsWhitelisted(ctx context.Context, userAddress stri
 	// Call the whitelist mapping on the token contract
 	contract := bind.NewBoundContract(bs.tokenAddress, bs.tokenABI, bs.client, bs.client, bs.client)
 	var result []interface{}
-	err := contract.Call(callOpts, &result, "whitelist", address)
+	err := bs.callError("whitelist", contract.Call(callOpts, &result, "whitelist", address))
 	if err != nil {
 		return false, fmt.Errorf("failed to check whitelist status: %w", err)
 	}
@@ -185,50 +288,99 @@ func (bs *BlockchainService) IsWhitelisted(ctx context.Context, userAddress stri
 	return result[0].(bool), nil
 }
 
-// AddToWhitelist adds addresses to the whitelist (requires admin privileges)
-func (bs *BlockchainService) AddToWhitelist(ctx context.Context, addresses []string) (*types.Transaction, error) {
-	if bs.privateKey == nil {
-		return nil, fmt.Errorf("private key not set")
+// IsWhitelistedBatch checks the whitelist mapping for many addresses. Calls are
+// batched through Multicall3 when it is deployed; otherwise, and for any call
+// that fails inside the batch, addresses are checked one by one.
+func (bs *BlockchainService) IsWhitelistedBatch(ctx context.Context, addresses []string) (map[string]bool, error) {
+	if bs.tokenAddress == (common.Address{}) {
+		return nil, fmt.Errorf("token address not set")
 	}
 
-	// For single address, call updateWhitelist on token contract
-	if len(addresses) == 1 {
-		address := common.HexToAddress(addresses[0])
-		return bs.executeTokenTransaction(ctx, "updateWhitelist", address, true)
+	result := make(map[string]bool, len(addresses))
+	pending := addresses
+
+	if bs.multicallAddress != (common.Address{}) && len(addresses) > 1 {
+		hasMulticall, err := bs.IsContract(ctx, bs.multicallAddress.Hex())
+		if err != nil {
+			return nil, err
+		}
+		if hasMulticall {
+			pending, err = bs.multicallWhitelisted(ctx, addresses, result)
+			if err != nil {
+				bs.logger.Warnf("Multicall whitelist check failed, falling back to single calls: %v", err)
+				pending = addresses
+			}
+		}
 	}
 
-	// For batch operations, call updateWhitelistBatch on token contract
-	addrs := make([]common.Address, len(addresses))
-	for i, addr := range addresses {
-		addrs[i] = common.HexToAddress(addr)
+	for _, address := range pending {
+		whitelisted, err := bs.IsWhitelisted(ctx, address, nil)
+		if err != nil {
+			return nil, err
+		}
+		result[address] = whitelisted
 	}
-	
-	return bs.executeTokenTransaction(ctx, "updateWhitelistBatch", addrs, true)
+
+	return result, nil
 }
 
-// RemoveFromWhitelist removes addresses from the whitelist
-func (bs *BlockchainService) RemoveFromWhitelist(ctx context.Context, addresses []string) (*types.Transaction, error) {
+// AddToWhitelist sends a transaction adding addresses to the whitelist
+// (requires admin privileges). It returns once the transaction is broadcast;
+// use WaitMined for the outcome.
+func (bs *BlockchainService) AddToWhitelist(ctx context.Context, addresses []string) (*models.Transaction, error) {
 	if bs.privateKey == nil {
 		return nil, fmt.Errorf("private key not set")
 	}
 
-	// For single address, call updateWhitelist on token contract
-	if len(addresses) == 1 {
-		address := common.HexToAddress(addresses[0])
-		return bs.executeTokenTransaction(ctx, "updateWhitelist", address, false)
+	method, params := whitelistCall(addresses, true)
+	return bs.executeTokenTransaction(ctx, method, params...)
+}
+
+// RemoveFromWhitelist sends a transaction removing addresses from the whitelist
+func (bs *BlockchainService) RemoveFromWhitelist(ctx context.Context, addresses []string) (*models.Transaction, error) {
+	if bs.privateKey == nil {
+		return nil, fmt.Errorf("private key not set")
 	}
 
-	// For batch operations, call updateWhitelistBatch on token contract
-	addrs := make([]common.Address, len(addresses))
-	for i, addr := range addresses {
-		addrs[i] = common.HexToAddress(addr)
+	method, params := whitelistCall(addresses, false)
+	return bs.executeTokenTransaction(ctx, method, params...)
+}
+
+// EstimateTransaction dry-runs the transaction an action would send, with
+// the same gas limit and cost checks that are applied before sending it
+func (bs *BlockchainService) EstimateTransaction(ctx context.Context, action string, addresses []string) (*GasEstimate, error) {
+	if bs.txManager == nil {
+		return nil, fmt.Errorf("transaction manager not set")
 	}
-	
-	return bs.executeTokenTransaction(ctx, "updateWhitelistBatch", addrs, false)
+
+	var req TxRequest
+	var err error
+	switch action {
+	case TxActionWhitelistAdd, TxActionWhitelistRemove:
+		if len(addresses) == 0 {
+			return nil, fmt.Errorf("%w: %s needs at least one address", ErrInvalidTxAction, action)
+		}
+		for _, address := range addresses {
+			if _, err := NormalizeAddress(address); err != nil {
+				return nil, err
+			}
+		}
+		method, params := whitelistCall(addresses, action == TxActionWhitelistAdd)
+		req, err = bs.tokenRequest(method, params...)
+	case TxActionPause, TxActionUnpause:
+		req, err = bs.saleRequest(action)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTxAction, action)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return bs.txManager.Estimate(ctx, req)
 }
 
-// PauseSale pauses the token sale
-func (bs *BlockchainService) PauseSale(ctx context.Context) (*types.Transaction, error) {
+// PauseSale sends a transaction pausing the token sale
+func (bs *BlockchainService) PauseSale(ctx context.Context) (*models.Transaction, error) {
 	if bs.privateKey == nil {
 		return nil, fmt.Errorf("private key not set")
 	}
@@ -236,8 +388,8 @@ func (bs *BlockchainService) PauseSale(ctx context.Context) (*types.Transaction,
 	return bs.executeTransaction(ctx, "pause")
 }
 
-// UnpauseSale unpauses the token sale
-func (bs *BlockchainService) UnpauseSale(ctx context.Context) (*types.Transaction, error) {
+// UnpauseSale sends a transaction unpausing the token sale
+func (bs *BlockchainService) UnpauseSale(ctx context.Context) (*models.Transaction, error) {
 	if bs.privateKey == nil {
 		return nil, fmt.Errorf("private key not set")
 	}
@@ -245,6 +397,36 @@ func (bs *BlockchainService) UnpauseSale(ctx context.Context) (*types.Transactio
 	return bs.executeTransaction(ctx, "unpause")
 }
 
+// WaitMined waits for a sent transaction, or a replacement with higher fees,
+// to be mined. A reverted transaction is returned with its receipt and an error.
+func (bs *BlockchainService) WaitMined(ctx context.Context, record *models.Transaction) (*types.Receipt, error) {
+	if bs.txManager == nil {
+		return nil, fmt.Errorf("transaction manager not set")
+	}
+
+	receipt, err := bs.txManager.WaitMined(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		if record.RevertReason != "" {
+			return receipt, fmt.Errorf("transaction %s reverted: %s", receipt.TxHash.Hex(), record.RevertReason)
+		}
+		return receipt, fmt.Errorf("transaction %s reverted", receipt.TxHash.Hex())
+	}
+
+	bs.logger.Infof("Transaction %s completed successfully (block %d)", receipt.TxHash.Hex(), receipt.BlockNumber.Uint64())
+	return receipt, nil
+}
+
+// GetTransaction returns the record of a transaction sent by the server
+func (bs *BlockchainService) GetTransaction(ctx context.Context, id uint) (*models.Transaction, error) {
+	if bs.txManager == nil {
+		return nil, fmt.Errorf("transaction manager not set")
+	}
+	return bs.txManager.Get(ctx, id)
+}
+
 // GetTokenBalance gets token balance for an address
 func (bs *BlockchainService) GetTokenBalance(ctx context.Context, address string) (*big.Int, error) {
 	if bs.tokenAddress == (common.Address{}) {
@@ -257,7 +439,7 @@ func (bs *BlockchainService) GetTokenBalance(ctx context.Context, address string
 	// Call balanceOf
 	contract := bind.NewBoundContract(bs.tokenAddress, bs.tokenABI, bs.client, bs.client, bs.client)
 	var result []interface{}
-	err := contract.Call(callOpts, &result, "balanceOf", addr)
+	err := bs.callError("balanceOf", contract.Call(callOpts, &result, "balanceOf", addr))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get token balance: %w", err)
 	}
@@ -265,54 +447,103 @@ func (bs *BlockchainService) GetTokenBalance(ctx context.Context, address string
 	return result[0].(*big.Int), nil
 }
 
-// WatchPurchaseEvents watches for token purchase events
-func (bs *BlockchainService) WatchPurchaseEvents(ctx context.Context, eventChan chan<- PurchaseEvent) error {
-	if bs.contractAddress == (common.Address{}) {
-		return fmt.Errorf("contract address not set")
+// SubscribeNewHead streams new block headers. It fails with
+// rpc.ErrNotificationsUnsupported when no websocket connection is available.
+func (bs *BlockchainService) SubscribeNewHead(ctx context.Context, heads chan<- *types.Header) (ethereum.Subscription, error) {
+	client := bs.client
+	if bs.wsClient != nil {
+		client = bs.wsClient
 	}
+	return client.SubscribeNewHead(ctx, heads)
+}
 
-	// Create filter query
-	query := ethereum.FilterQuery{
-		Addresses: []common.Address{bs.contractAddress},
-		Topics: [][]common.Hash{
-			{crypto.Keccak256Hash([]byte("TokenPurchase(address,uint256,uint256,uint256)"))},
-		},
-	}
+// BlockNumber returns the latest block number
+func (bs *BlockchainService) BlockNumber(ctx context.Context) (uint64, error) {
+	return bs.client.BlockNumber(ctx)
+}
 
-	// Subscribe to logs
-	logs := make(chan types.Log)
-	sub, err := bs.client.SubscribeFilterLogs(ctx, query, logs)
+// HeaderByNumber returns the header of a block
+func (bs *BlockchainService) HeaderByNumber(ctx context.Context, number uint64) (*types.Header, error) {
+	header, err := bs.client.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
 	if err != nil {
-		return fmt.Errorf("failed to subscribe to logs: %w", err)
-	}
-
-	go func() {
-		defer sub.Unsubscribe()
-		for {
-			select {
-			case err := <-sub.Err():
-				bs.logger.Errorf("Subscription error: %v", err)
-				return
-			case vLog := <-logs:
-				// Parse the log
-				event, err := bs.parsePurchaseEvent(vLog)
-				if err != nil {
-					bs.logger.Errorf("Failed to parse purchase event: %v", err)
-					continue
-				}
-				
-				select {
-				case eventChan <- *event:
-				case <-ctx.Done():
-					return
-				}
-			case <-ctx.Done():
-				return
-			}
+		return nil, fmt.Errorf("failed to get block header %d: %w", number, err)
+	}
+	return header, nil
+}
+
+// FilterContractLogs returns the logs of the events followed by the indexer,
+// emitted by the sale and token contracts between fromBlock and toBlock
+// inclusive, in chain order
+func (bs *BlockchainService) FilterContractLogs(ctx context.Context, fromBlock, toBlock uint64) ([]types.Log, error) {
+	var addresses []common.Address
+	var topics []common.Hash
+	if bs.contractAddress != (common.Address{}) {
+		addresses = append(addresses, bs.contractAddress)
+		for _, name := range saleEvents {
+			topics = append(topics, bs.saleABI.Events[name].ID)
+		}
+	}
+	if bs.tokenAddress != (common.Address{}) {
+		addresses = append(addresses, bs.tokenAddress)
+		for _, name := range tokenEvents {
+			topics = append(topics, bs.tokenABI.Events[name].ID)
 		}
-	}()
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("contract addresses not set")
+	}
 
-	return nil
+	logs, err := bs.client.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(fromBlock),
+		ToBlock:   new(big.Int).SetUint64(toBlock),
+		Addresses: addresses,
+		Topics:    [][]common.Hash{topics},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter contract logs: %w", err)
+	}
+	return logs, nil
+}
+
+// DecodeContractLog decodes a log with the ABI of the contract that emitted
+// it into the typed arguments of its event. Malformed logs, such as short
+// data or a topic count that does not match the event definition, return an
+// error.
+func (bs *BlockchainService) DecodeContractLog(vLog types.Log) (*ContractEvent, error) {
+	var contractABI abi.ABI
+	switch vLog.Address {
+	case bs.contractAddress:
+		contractABI = bs.saleABI
+	case bs.tokenAddress:
+		contractABI = bs.tokenABI
+	default:
+		return nil, fmt.Errorf("log from unknown contract %s", vLog.Address.Hex())
+	}
+	if len(vLog.Topics) == 0 {
+		return nil, fmt.Errorf("anonymous log in tx %s", vLog.TxHash.Hex())
+	}
+
+	event, err := contractABI.EventByID(vLog.Topics[0])
+	if err != nil {
+		return nil, fmt.Errorf("unknown event %s in tx %s", vLog.Topics[0].Hex(), vLog.TxHash.Hex())
+	}
+	args := newEventArgs(event.Name)
+	if args == nil {
+		return nil, fmt.Errorf("event %s is not decoded", event.Name)
+	}
+	if err := unpackLog(contractABI, event, vLog, args); err != nil {
+		return nil, err
+	}
+
+	return &ContractEvent{
+		Name:        event.Name,
+		Contract:    vLog.Address,
+		Args:        args,
+		BlockNumber: vLog.BlockNumber,
+		BlockHash:   vLog.BlockHash,
+		TxHash:      vLog.TxHash,
+		LogIndex:    vLog.Index,
+	}, nil
 }
 
 // Helper methods
@@ -321,105 +552,201 @@ func (bs *BlockchainService) callContract(opts *bind.CallOpts, method string) ([
 	contract := bind.NewBoundContract(bs.contractAddress, bs.saleABI, bs.client, bs.client, bs.client)
 	var result []interface{}
 	err := contract.Call(opts, &result, method)
-	return result, err
+	return result, bs.callError(method, err)
 }
 
 func (bs *BlockchainService) callContractWithParams(opts *bind.CallOpts, method string, params ...interface{}) ([]interface{}, error) {
 	contract := bind.NewBoundContract(bs.contractAddress, bs.saleABI, bs.client, bs.client, bs.client)
 	var result []interface{}
 	err := contract.Call(opts, &result, method, params...)
-	return result, err
+	return result, bs.callError(method, err)
 }
 
-func (bs *BlockchainService) executeTransaction(ctx context.Context, method string, params ...interface{}) (*types.Transaction, error) {
-	// Get chain ID
-	chainID, err := bs.client.NetworkID(ctx)
+// executeTransaction sends a transaction to the sale contract without waiting
+// for it to be mined
+func (bs *BlockchainService) executeTransaction(ctx context.Context, method string, params ...interface{}) (*models.Transaction, error) {
+	req, err := bs.saleRequest(method, params...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get chain ID: %w", err)
+		return nil, err
 	}
 
-	// Create transactor
-	auth, err := bind.NewKeyedTransactorWithChainID(bs.privateKey, chainID)
+	record, _, err := bs.sendTransaction(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create transactor: %w", err)
+		return record, fmt.Errorf("failed to execute transaction: %w", err)
 	}
+	return record, nil
+}
 
-	// Set gas parameters
-	auth.GasLimit = uint64(300000) // Adjust based on method
-	gasPrice, err := bs.client.SuggestGasPrice(ctx)
+// executeTokenTransaction sends a transaction to the token contract without
+// waiting for it to be mined
+func (bs *BlockchainService) executeTokenTransaction(ctx context.Context, method string, params ...interface{}) (*models.Transaction, error) {
+	req, err := bs.tokenRequest(method, params...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get gas price: %w", err)
+		return nil, err
 	}
-	auth.GasPrice = gasPrice
 
-	// Execute transaction on sale contract
-	contract := bind.NewBoundContract(bs.contractAddress, bs.saleABI, bs.client, bs.client, bs.client)
-	tx, err := contract.Transact(auth, method, params...)
+	record, _, err := bs.sendTransaction(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute transaction: %w", err)
+		return record, fmt.Errorf("failed to execute transaction: %w", err)
 	}
-
-	return tx, nil
+	return record, nil
 }
 
-func (bs *BlockchainService) executeTokenTransaction(ctx context.Context, method string, params ...interface{}) (*types.Transaction, error) {
-	// Get chain ID
-	chainID, err := bs.client.NetworkID(ctx)
+// saleRequest encodes a call to the sale contract
+func (bs *BlockchainService) saleRequest(method string, params ...interface{}) (TxRequest, error) {
+	data, err := bs.saleABI.Pack(method, params...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get chain ID: %w", err)
+		return TxRequest{}, fmt.Errorf("failed to encode %s call: %w", method, err)
 	}
+	return TxRequest{To: bs.contractAddress, Method: method, Params: params, Data: data}, nil
+}
 
-	// Create transactor
-	auth, err := bind.NewKeyedTransactorWithChainID(bs.privateKey, chainID)
+// tokenRequest encodes a call to the token contract
+func (bs *BlockchainService) tokenRequest(method string, params ...interface{}) (TxRequest, error) {
+	// Validate method exists in ABI
+	if _, exists := bs.tokenABI.Methods[method]; !exists {
+		return TxRequest{}, fmt.Errorf("method %s not found in ABI", method)
+	}
+	data, err := bs.tokenABI.Pack(method, params...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create transactor: %w", err)
+		return TxRequest{}, fmt.Errorf("failed to encode %s call: %w", method, err)
 	}
+	return TxRequest{To: bs.tokenAddress, Method: method, Params: params, Data: data}, nil
+}
 
-	// Set gas parameters
-	auth.GasLimit = uint64(300000)
-	gasPrice, err := bs.client.SuggestGasPrice(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get gas price: %w", err)
+// sendTransaction sends a write through the transaction manager, which
+// assigns the nonce
+func (bs *BlockchainService) sendTransaction(ctx context.Context, req TxRequest) (*models.Transaction, *types.Transaction, error) {
+	if bs.txManager == nil {
+		return nil, nil, fmt.Errorf("transaction manager not set")
 	}
-	auth.GasPrice = gasPrice
+	return bs.txManager.Send(ctx, req)
+}
 
-	// Validate method exists in ABI
-	if _, exists := bs.tokenABI.Methods[method]; !exists {
-		return nil, fmt.Errorf("method %s not found in ABI", method)
+// whitelistCall returns the token contract call that sets the whitelist
+// status of addresses: updateWhitelist for one, updateWhitelistBatch for more
+func whitelistCall(addresses []string, status bool) (string, []interface{}) {
+	if len(addresses) == 1 {
+		return "updateWhitelist", []interface{}{common.HexToAddress(addresses[0]), status}
 	}
 
-	// Execute transaction on token contract
-	contract := bind.NewBoundContract(bs.tokenAddress, bs.tokenABI, bs.client, bs.client, bs.client)
-	tx, err := contract.Transact(auth, method, params...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute transaction: %w", err)
+	addrs := make([]common.Address, len(addresses))
+	for i, addr := range addresses {
+		addrs[i] = common.HexToAddress(addr)
 	}
+	return "updateWhitelistBatch", []interface{}{addrs, status}
+}
 
-	// Wait for the transaction to be mined
-	receipt, err := bind.WaitMined(ctx, bs.client, tx)
-	if err != nil {
-		return tx, err // Return transaction even if waiting fails
+// multicall3Call and multicall3Result mirror Multicall3's Call3 and Result structs
+type multicall3Call struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type multicall3Result struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// multicallWhitelisted fills result from a single aggregate3 call and returns the addresses whose call failed
+func (bs *BlockchainService) multicallWhitelisted(ctx context.Context, addresses []string, result map[string]bool) ([]string, error) {
+	calls := make([]multicall3Call, len(addresses))
+	for i, address := range addresses {
+		callData, err := bs.tokenABI.Pack("whitelist", common.HexToAddress(address))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode whitelist call: %w", err)
+		}
+		calls[i] = multicall3Call{Target: bs.tokenAddress, AllowFailure: true, CallData: callData}
 	}
 
-	// Check transaction status
-	if receipt.Status == 0 {
-		return tx, fmt.Errorf("transaction failed")
+	contract := bind.NewBoundContract(bs.multicallAddress, bs.multicallABI, bs.client, bs.client, bs.client)
+	var out []interface{}
+	if err := contract.Call(&bind.CallOpts{Context: ctx}, &out, "aggregate3", calls); err != nil {
+		return nil, fmt.Errorf("failed to call aggregate3: %w", err)
 	}
 
-	bs.logger.Infof("Transaction %s completed successfully (block %d)", tx.Hash().Hex(), receipt.BlockNumber.Uint64())
-	return tx, nil
+	results := *abi.ConvertType(out[0], new([]multicall3Result)).(*[]multicall3Result)
+	if len(results) != len(addresses) {
+		return nil, fmt.Errorf("aggregate3 returned %d results for %d calls", len(results), len(addresses))
+	}
+
+	var failed []string
+	for i, res := range results {
+		if !res.Success {
+			failed = append(failed, addresses[i])
+			continue
+		}
+		values, err := bs.tokenABI.Unpack("whitelist", res.ReturnData)
+		if err != nil || len(values) == 0 {
+			failed = append(failed, addresses[i])
+			continue
+		}
+		result[addresses[i]] = values[0].(bool)
+	}
+
+	return failed, nil
 }
 
-func (bs *BlockchainService) parsePurchaseEvent(vLog types.Log) (*PurchaseEvent, error) {
-	// This is a simplified version - in practice, you'd use the ABI to properly decode
-	return &PurchaseEvent{
-		Buyer:       common.BytesToAddress(vLog.Topics[1].Bytes()),
-		TokenAmount: new(big.Int).SetBytes(vLog.Data[0:32]),
-		EthAmount:   new(big.Int).SetBytes(vLog.Data[32:64]),
-		Timestamp:   new(big.Int).SetBytes(vLog.Data[64:96]),
-		TxHash:      vLog.TxHash,
-		BlockNumber: vLog.BlockNumber,
-	}, nil
+// isExecutionReverted distinguishes a contract revert from a transport or node failure.
+// Every JSON-RPC error carries a code and optional data, so only code 3 with
+// revert data counts, as geth reports reverts, or a revert message.
+func isExecutionReverted(err error) bool {
+	var rpcErr rpc.Error
+	var dataErr rpc.DataError
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == 3 && errors.As(err, &dataErr) {
+		if data, ok := dataErr.ErrorData().(string); ok && strings.HasPrefix(data, "0x") {
+			return true
+		}
+	}
+	// Calls that return nothing decode as an unmarshalling error, e.g. a contract without isValidSignature
+	if errors.Is(err, bind.ErrNoCode) || strings.Contains(err.Error(), "abi: attempting to unmarshall an empty string") {
+		return true
+	}
+	return strings.Contains(err.Error(), "execution reverted")
+}
+
+// newEventArgs returns the struct an event's arguments are decoded into.
+// Field names are the ABI argument names in CamelCase, as ParseTopics and
+// UnpackIntoInterface expect.
+func newEventArgs(name string) interface{} {
+	switch name {
+	case EventTokenPurchase:
+		return new(TokenPurchaseEvent)
+	case EventTokensClaimed:
+		return new(TokensClaimedEvent)
+	case EventPaused, EventUnpaused:
+		return new(PauseEvent)
+	case EventTransfer:
+		return new(TransferEvent)
+	case EventWhitelistUpdated:
+		return new(WhitelistUpdatedEvent)
+	}
+	return nil
+}
+
+// unpackLog decodes the non-indexed arguments from the log data and the
+// indexed ones from its topics
+func unpackLog(contractABI abi.ABI, event *abi.Event, vLog types.Log, out interface{}) error {
+	if len(event.Inputs.NonIndexed()) > 0 {
+		if err := contractABI.UnpackIntoInterface(out, event.Name, vLog.Data); err != nil {
+			return fmt.Errorf("failed to decode %s data in tx %s: %w", event.Name, vLog.TxHash.Hex(), err)
+		}
+	}
+
+	var indexed abi.Arguments
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+	if len(vLog.Topics)-1 != len(indexed) {
+		return fmt.Errorf("%s in tx %s has %d indexed topics, expected %d", event.Name, vLog.TxHash.Hex(), len(vLog.Topics)-1, len(indexed))
+	}
+	if err := abi.ParseTopics(out, indexed, vLog.Topics[1:]); err != nil {
+		return fmt.Errorf("failed to decode %s topics in tx %s: %w", event.Name, vLog.TxHash.Hex(), err)
+	}
+	return nil
 }
 
 // Data structures
@@ -446,16 +773,125 @@ type UserPurchaseInfo struct {
 	TotalPurchased *big.Int  `json:"total_purchased"`
 }
 
-type PurchaseEvent struct {
+// TokenPurchaseEvent is the sale contract's TokenPurchase event
+type TokenPurchaseEvent struct {
 	Buyer       common.Address `json:"buyer"`
 	TokenAmount *big.Int       `json:"token_amount"`
 	EthAmount   *big.Int       `json:"eth_amount"`
 	Timestamp   *big.Int       `json:"timestamp"`
-	TxHash      common.Hash    `json:"tx_hash"`
+}
+
+// TokensClaimedEvent is the sale contract's TokensClaimed event
+type TokensClaimedEvent struct {
+	User   common.Address `json:"user"`
+	Amount *big.Int       `json:"amount"`
+}
+
+// PauseEvent is the sale contract's Paused or Unpaused event
+type PauseEvent struct {
+	Account common.Address `json:"account"`
+}
+
+// TransferEvent is the token's ERC-20 Transfer event
+type TransferEvent struct {
+	From  common.Address `json:"from"`
+	To    common.Address `json:"to"`
+	Value *big.Int       `json:"value"`
+}
+
+// WhitelistUpdatedEvent is the token's WhitelistUpdated event
+type WhitelistUpdatedEvent struct {
+	User   common.Address `json:"user"`
+	Status bool           `json:"status"`
+}
+
+// ContractEvent is a log decoded with its contract's ABI. Args points to the
+// event's typed arguments, e.g. *TokenPurchaseEvent.
+type ContractEvent struct {
+	Name        string         `json:"name"`
+	Contract    common.Address `json:"contract"`
+	Args        interface{}    `json:"args"`
 	BlockNumber uint64         `json:"block_number"`
+	BlockHash   common.Hash    `json:"block_hash"`
+	TxHash      common.Hash    `json:"tx_hash"`
+	LogIndex    uint           `json:"log_index"`
 }
 
-// ABI definitions (simplified - in practice, load from files or generate with abigen)
+// Write actions that can be dry-run with EstimateTransaction
+const (
+	TxActionWhitelistAdd    = "whitelist_add"
+	TxActionWhitelistRemove = "whitelist_remove"
+	TxActionPause           = "pause"
+	TxActionUnpause         = "unpause"
+)
+
+// ErrInvalidTxAction is returned for an unknown or incomplete write action
+var ErrInvalidTxAction = errors.New("invalid transaction action")
+
+// Contract events followed by the indexer
+const (
+	EventTokenPurchase    = "TokenPurchase"
+	EventTokensClaimed    = "TokensClaimed"
+	EventPaused           = "Paused"
+	EventUnpaused         = "Unpaused"
+	EventTransfer         = "Transfer"
+	EventWhitelistUpdated = "WhitelistUpdated"
+)
+
+var (
+	saleEvents  = []string{EventTokenPurchase, EventTokensClaimed, EventPaused, EventUnpaused}
+	tokenEvents = []string{EventTransfer, EventWhitelistUpdated}
+)
+
+// erc1271MagicValue is bytes4(keccak256("isValidSignature(bytes32,bytes)"))
+var erc1271MagicValue = [4]byte{0x16, 0x26, 0xba, 0x7e}
+
+const ERC1271ABI = `[
+	{
+		"inputs": [
+			{"internalType": "bytes32", "name": "hash", "type": "bytes32"},
+			{"internalType": "bytes", "name": "signature", "type": "bytes"}
+		],
+		"name": "isValidSignature",
+		"outputs": [{"internalType": "bytes4", "name": "magicValue", "type": "bytes4"}],
+		"stateMutability": "view",
+		"type": "function"
+	}
+]`
+
+const Multicall3ABI = `[
+	{
+		"inputs": [
+			{
+				"components": [
+					{"internalType": "address", "name": "target", "type": "address"},
+					{"internalType": "bool", "name": "allowFailure", "type": "bool"},
+					{"internalType": "bytes", "name": "callData", "type": "bytes"}
+				],
+				"internalType": "struct Multicall3.Call3[]",
+				"name": "calls",
+				"type": "tuple[]"
+			}
+		],
+		"name": "aggregate3",
+		"outputs": [
+			{
+				"components": [
+					{"internalType": "bool", "name": "success", "type": "bool"},
+					{"internalType": "bytes", "name": "returnData", "type": "bytes"}
+				],
+				"internalType": "struct Multicall3.Result[]",
+				"name": "returnData",
+				"type": "tuple[]"
+			}
+		],
+		"stateMutability": "payable",
+		"type": "function"
+	}
+]`
+
+// ABI definitions used when no Hardhat artifacts are configured. They cover
+// the methods and events the service uses; artifacts are validated against them.
 const WhitelistSaleABI = `[
 	{
 		"inputs": [],
@@ -471,6 +907,92 @@ const WhitelistSaleABI = `[
 		],
 		"stateMutability": "view",
 		"type": "function"
+	},
+	{
+		"inputs": [],
+		"name": "totalSold",
+		"outputs": [{"internalType": "uint256", "name": "", "type": "uint256"}],
+		"stateMutability": "view",
+		"type": "function"
+	},
+	{
+		"inputs": [],
+		"name": "totalEthRaised",
+		"outputs": [{"internalType": "uint256", "name": "", "type": "uint256"}],
+		"stateMutability": "view",
+		"type": "function"
+	},
+	{
+		"inputs": [],
+		"name": "isSaleActive",
+		"outputs": [{"internalType": "bool", "name": "", "type": "bool"}],
+		"stateMutability": "view",
+		"type": "function"
+	},
+	{
+		"inputs": [{"internalType": "address", "name": "user", "type": "address"}],
+		"name": "getPurchaseInfo",
+		"outputs": [
+			{"internalType": "uint256", "name": "amount", "type": "uint256"},
+			{"internalType": "uint256", "name": "ethSpent", "type": "uint256"},
+			{"internalType": "uint256", "name": "timestamp", "type": "uint256"},
+			{"internalType": "bool", "name": "claimed", "type": "bool"}
+		],
+		"stateMutability": "view",
+		"type": "function"
+	},
+	{
+		"inputs": [{"internalType": "address", "name": "", "type": "address"}],
+		"name": "totalPurchased",
+		"outputs": [{"internalType": "uint256", "name": "", "type": "uint256"}],
+		"stateMutability": "view",
+		"type": "function"
+	},
+	{
+		"inputs": [],
+		"name": "pause",
+		"outputs": [],
+		"stateMutability": "nonpayable",
+		"type": "function"
+	},
+	{
+		"inputs": [],
+		"name": "unpause",
+		"outputs": [],
+		"stateMutability": "nonpayable",
+		"type": "function"
+	},
+	{
+		"anonymous": false,
+		"inputs": [
+			{"indexed": true, "internalType": "address", "name": "buyer", "type": "address"},
+			{"indexed": false, "internalType": "uint256", "name": "tokenAmount", "type": "uint256"},
+			{"indexed": false, "internalType": "uint256", "name": "ethAmount", "type": "uint256"},
+			{"indexed": false, "internalType": "uint256", "name": "timestamp", "type": "uint256"}
+		],
+		"name": "TokenPurchase",
+		"type": "event"
+	},
+	{
+		"anonymous": false,
+		"inputs": [
+			{"indexed": true, "internalType": "address", "name": "user", "type": "address"},
+			{"indexed": false, "internalType": "uint256", "name": "amount", "type": "uint256"}
+		],
+		"name": "TokensClaimed",
+		"type": "event"
+	},
+	{
+		"anonymous": false,
+		"inputs": [{"indexed": false, "internalType": "address", "name": "account", "type": "address"}],
+		"name": "Paused",
+		"type": "event"
+	},
+	{
+		"anonymous": false,
+		"inputs": [{"indexed": false, "internalType": "address", "name": "account", "type": "address"}],
+		"name": "Unpaused",
+		"type": "event"
 	}
 ]`
 
@@ -508,5 +1030,24 @@ const WhitelistTokenABI = `[
 		"outputs": [],
 		"stateMutability": "nonpayable",
 		"type": "function"
+	},
+	{
+		"anonymous": false,
+		"inputs": [
+			{"indexed": true, "internalType": "address", "name": "user", "type": "address"},
+			{"indexed": false, "internalType": "bool", "name": "status", "type": "bool"}
+		],
+		"name": "WhitelistUpdated",
+		"type": "event"
+	},
+	{
+		"anonymous": false,
+		"inputs": [
+			{"indexed": true, "internalType": "address", "name": "from", "type": "address"},
+			{"indexed": true, "internalType": "address", "name": "to", "type": "address"},
+			{"indexed": false, "internalType": "uint256", "name": "value", "type": "uint256"}
+		],
+		"name": "Transfer",
+		"type": "event"
 	}
 ]`
\ No newline at end of file
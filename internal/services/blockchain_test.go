@@ -0,0 +1,78 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// nodeError is returned by nodeService so the client sees a real JSON-RPC error
+type nodeError struct {
+	code    int
+	message string
+	data    interface{}
+}
+
+func (e *nodeError) Error() string          { return e.message }
+func (e *nodeError) ErrorCode() int         { return e.code }
+func (e *nodeError) ErrorData() interface{} { return e.data }
+
+type nodeService struct{}
+
+func (nodeService) Fail(code int, message string, data *string) error {
+	err := &nodeError{code: code, message: message}
+	if data != nil {
+		err.data = *data
+	}
+	return err
+}
+
+// rpcError returns the error a client gets back for a node error
+func rpcError(t *testing.T, code int, message string, data *string) error {
+	t.Helper()
+	server := rpc.NewServer()
+	if err := server.RegisterName("node", nodeService{}); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	err := client.Call(nil, "node_fail", code, message, data)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	return err
+}
+
+func TestIsExecutionReverted(t *testing.T) {
+	revertData := "0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000004" +
+		"6e6f706500000000000000000000000000000000000000000000000000000000"
+	customData := "0x1234abcd"
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"revert with data", rpcError(t, 3, "execution reverted: nope", &revertData), true},
+		{"custom error with data", rpcError(t, 3, "execution reverted", &customData), true},
+		{"revert message without data", rpcError(t, -32000, "execution reverted", nil), true},
+		{"header not found", rpcError(t, -32000, "header not found", nil), false},
+		{"insufficient funds", rpcError(t, -32000, "insufficient funds for gas * price + value", nil), false},
+		{"code 3 without revert data", rpcError(t, 3, "something else", nil), false},
+		{"transport failure", errors.New("dial tcp 127.0.0.1:8545: connect: connection refused"), false},
+		{"no contract code", fmt.Errorf("call failed: %w", bind.ErrNoCode), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isExecutionReverted(tt.err); got != tt.want {
+				t.Fatalf("isExecutionReverted(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Methods the service calls on each contract. Artifacts loaded at startup
+// must define them, and the sale and token events, exactly as the embedded
+// ABIs do, since results and logs are decoded by position and name.
+var (
+	saleMethods  = []string{"saleConfig", "totalSold", "totalEthRaised", "isSaleActive", "getPurchaseInfo", "totalPurchased", "pause", "unpause"}
+	tokenMethods = []string{"balanceOf", "whitelist", "updateWhitelist", "updateWhitelistBatch"}
+)
+
+// hardhatArtifact is the part of a Hardhat artifact file
+// (artifacts/contracts/<Name>.sol/<Name>.json) that is used
+type hardhatArtifact struct {
+	ABI json.RawMessage `json:"abi"`
+}
+
+// LoadArtifacts replaces the embedded sale and token ABIs with the ones in
+// Hardhat artifact files. An empty path keeps the embedded ABI. Both ABIs
+// are checked against the methods and events the service uses.
+func (bs *BlockchainService) LoadArtifacts(saleArtifactPath, tokenArtifactPath string) error {
+	saleABI, tokenABI := bs.saleABI, bs.tokenABI
+	var err error
+	if saleArtifactPath != "" {
+		if saleABI, err = loadArtifactABI(saleArtifactPath); err != nil {
+			return err
+		}
+	}
+	if tokenArtifactPath != "" {
+		if tokenABI, err = loadArtifactABI(tokenArtifactPath); err != nil {
+			return err
+		}
+	}
+
+	if err := validateContractABIs(saleABI, tokenABI); err != nil {
+		return err
+	}
+	bs.saleABI, bs.tokenABI = saleABI, tokenABI
+	return nil
+}
+
+// CheckDeployedContracts looks for the selector of every method the service
+// calls in the deployed bytecode and returns the ones that are missing. Proxy
+// contracts delegate to code elsewhere, so a miss is a hint, not proof.
+func (bs *BlockchainService) CheckDeployedContracts(ctx context.Context) ([]string, error) {
+	var missing []string
+	check := func(address common.Address, contractABI abi.ABI, methods []string) error {
+		if address == (common.Address{}) {
+			return nil
+		}
+		code, err := bs.client.CodeAt(ctx, address, nil)
+		if err != nil {
+			return fmt.Errorf("failed to get code at %s: %w", address.Hex(), err)
+		}
+		if len(code) == 0 {
+			return fmt.Errorf("no contract deployed at %s", address.Hex())
+		}
+		for _, name := range methods {
+			if !bytes.Contains(code, selectorPush(contractABI.Methods[name].ID)) {
+				missing = append(missing, contractABI.Methods[name].Sig)
+			}
+		}
+		return nil
+	}
+
+	if err := check(bs.contractAddress, bs.saleABI, saleMethods); err != nil {
+		return nil, err
+	}
+	if err := check(bs.tokenAddress, bs.tokenABI, tokenMethods); err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// loadArtifactABI reads the ABI from a Hardhat artifact, or from a file that
+// holds just the ABI array, e.g. one exported by solc or Foundry
+func loadArtifactABI(path string) (abi.ABI, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return abi.ABI{}, fmt.Errorf("failed to read contract artifact: %w", err)
+	}
+
+	raw := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(raw, []byte("[")) {
+		var artifact hardhatArtifact
+		if err := json.Unmarshal(raw, &artifact); err != nil {
+			return abi.ABI{}, fmt.Errorf("failed to parse contract artifact %s: %w", path, err)
+		}
+		if len(artifact.ABI) == 0 {
+			return abi.ABI{}, fmt.Errorf("contract artifact %s has no abi", path)
+		}
+		raw = artifact.ABI
+	}
+
+	contractABI, err := abi.JSON(bytes.NewReader(raw))
+	if err != nil {
+		return abi.ABI{}, fmt.Errorf("failed to parse ABI in %s: %w", path, err)
+	}
+	return contractABI, nil
+}
+
+// validateContractABIs checks the sale and token ABIs against the embedded ones
+func validateContractABIs(saleABI, tokenABI abi.ABI) error {
+	expectedSale, err := abi.JSON(strings.NewReader(WhitelistSaleABI))
+	if err != nil {
+		return fmt.Errorf("failed to parse sale ABI: %w", err)
+	}
+	expectedToken, err := abi.JSON(strings.NewReader(WhitelistTokenABI))
+	if err != nil {
+		return fmt.Errorf("failed to parse token ABI: %w", err)
+	}
+
+	if err := validateABI("sale", saleABI, expectedSale, saleMethods, saleEvents); err != nil {
+		return err
+	}
+	return validateABI("token", tokenABI, expectedToken, tokenMethods, tokenEvents)
+}
+
+// validateABI reports every method or event of contractABI that is missing or
+// differs from its definition in expected
+func validateABI(contract string, contractABI, expected abi.ABI, methods, events []string) error {
+	var problems []string
+	for _, name := range methods {
+		want := expected.Methods[name]
+		got, ok := contractABI.Methods[name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("method %s is missing", want.Sig))
+		case got.Sig != want.Sig:
+			problems = append(problems, fmt.Sprintf("method %s is %s", want.Sig, got.Sig))
+		case argumentTypes(got.Outputs) != argumentTypes(want.Outputs):
+			problems = append(problems, fmt.Sprintf("method %s returns (%s), expected (%s)", want.Sig, argumentTypes(got.Outputs), argumentTypes(want.Outputs)))
+		}
+	}
+	for _, name := range events {
+		want := expected.Events[name]
+		got, ok := contractABI.Events[name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("event %s is missing", want.Sig))
+		case eventInputs(got) != eventInputs(want):
+			problems = append(problems, fmt.Sprintf("event %s is %s, expected %s", name, eventInputs(got), eventInputs(want)))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s contract ABI is incompatible: %s", contract, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// selectorPush returns the PUSH instruction Solidity's dispatcher uses to
+// compare a selector. Leading zero bytes are dropped, e.g. PUSH3 instead of
+// PUSH4 for 0x00abcdef.
+func selectorPush(selector []byte) []byte {
+	trimmed := bytes.TrimLeft(selector, "\x00")
+	return append([]byte{0x5f + byte(len(trimmed))}, trimmed...)
+}
+
+func argumentTypes(args abi.Arguments) string {
+	types := make([]string, len(args))
+	for i, arg := range args {
+		types[i] = arg.Type.String()
+	}
+	return strings.Join(types, ",")
+}
+
+// eventInputs describes an event's inputs including names and indexed flags,
+// which decoding into the typed event structs depends on
+func eventInputs(event abi.Event) string {
+	inputs := make([]string, len(event.Inputs))
+	for i, input := range event.Inputs {
+		inputs[i] = input.Type.String()
+		if input.Indexed {
+			inputs[i] += " indexed"
+		}
+		inputs[i] += " " + input.Name
+	}
+	return event.Name + "(" + strings.Join(inputs, ", ") + ")"
+}
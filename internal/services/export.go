@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"whitelist-token-backend/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Export errors returned to handlers
+var (
+	ErrBlockNotIndexed     = errors.New("whitelist events are not indexed up to the requested block")
+	ErrSnapshotUnavailable = errors.New("historical snapshots are only available in mapping mode")
+)
+
+// Export formats
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatJSON   = "json"
+	ExportFormatMerkle = "merkle"
+)
+
+// ExportRow is one whitelisted address in an export. Allocation is the
+// entry's own allocation, as imports take it; EffectiveAllocation includes
+// the tier cap or sale maximum it falls back to.
+type ExportRow struct {
+	Address             string `json:"address"`
+	Allocation          string `json:"allocation"`
+	EffectiveAllocation string `json:"effective_allocation"`
+	Tier                string `json:"tier,omitempty"`
+}
+
+// WhitelistSnapshot is the whitelist at a point in time. Block is nil for the
+// current database state.
+type WhitelistSnapshot struct {
+	Block       *uint64     `json:"block,omitempty"`
+	GeneratedAt time.Time   `json:"generated_at"`
+	Count       int         `json:"count"`
+	Entries     []ExportRow `json:"entries"`
+}
+
+// merkleInput is the values/leafEncoding layout accepted by OpenZeppelin's
+// StandardMerkleTree.of, with the root this service would generate
+type merkleInput struct {
+	LeafEncoding []string    `json:"leafEncoding"`
+	Values       [][2]string `json:"values"`
+	Root         string      `json:"root,omitempty"`
+	Block        *uint64     `json:"block,omitempty"`
+}
+
+// ExportService exports the whitelist, current or rebuilt as of any block
+// covered by the chain indexer's WhitelistUpdated events
+type ExportService struct {
+	db               *gorm.DB
+	whitelistService *WhitelistService
+	logger           *logrus.Logger
+}
+
+// NewExportService creates a new export service
+func NewExportService(db *gorm.DB, whitelistService *WhitelistService, logger *logrus.Logger) *ExportService {
+	return &ExportService{
+		db:               db,
+		whitelistService: whitelistService,
+		logger:           logger,
+	}
+}
+
+// Snapshot returns the whitelisted addresses, either as currently stored or,
+// when block is set, as of that block according to the indexed events.
+// Allocations and tiers are kept off-chain, so historical snapshots report
+// their current values.
+func (es *ExportService) Snapshot(ctx context.Context, block *uint64) (*WhitelistSnapshot, error) {
+	snapshot := &WhitelistSnapshot{Block: block, GeneratedAt: time.Now(), Entries: []ExportRow{}}
+	limits, err := es.whitelistService.allocationLimits(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if block == nil {
+		var entries []models.WhitelistEntry
+		if err := es.db.WithContext(ctx).Where("is_whitelisted = ?", true).Order("address").Find(&entries).Error; err != nil {
+			return nil, fmt.Errorf("failed to load whitelist entries: %w", err)
+		}
+		for _, entry := range entries {
+			snapshot.Entries = append(snapshot.Entries, exportRow(entry.Address, entry, limits))
+		}
+		snapshot.Count = len(snapshot.Entries)
+		return snapshot, nil
+	}
+
+	if es.whitelistService.Mode() != WhitelistModeMapping {
+		return nil, ErrSnapshotUnavailable
+	}
+	cursor, err := es.cursor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cursor == nil || cursor.BlockNumber < *block {
+		return nil, ErrBlockNotIndexed
+	}
+
+	// The latest event at or before the block decides each address's state
+	var addresses []string
+	err = es.db.WithContext(ctx).Raw(`
+		SELECT address FROM (
+			SELECT DISTINCT ON (address) address, whitelisted
+			FROM whitelist_events
+			WHERE block_number <= ?
+			ORDER BY address, block_number DESC, log_index DESC
+		) latest
+		WHERE whitelisted
+		ORDER BY address`, *block).Scan(&addresses).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay whitelist events: %w", err)
+	}
+
+	var entries []models.WhitelistEntry
+	if len(addresses) > 0 {
+		if err := es.db.WithContext(ctx).Where("address IN ?", addresses).Find(&entries).Error; err != nil {
+			return nil, fmt.Errorf("failed to load whitelist entries: %w", err)
+		}
+	}
+	byAddress := make(map[string]models.WhitelistEntry, len(entries))
+	for _, entry := range entries {
+		byAddress[entry.Address] = entry
+	}
+	for _, address := range addresses {
+		snapshot.Entries = append(snapshot.Entries, exportRow(address, byAddress[address], limits))
+	}
+	snapshot.Count = len(snapshot.Entries)
+	return snapshot, nil
+}
+
+// IndexedBlock returns the last block processed by the chain indexer, or nil
+// if indexing has not started
+func (es *ExportService) IndexedBlock(ctx context.Context) (*uint64, error) {
+	cursor, err := es.cursor(ctx)
+	if err != nil || cursor == nil {
+		return nil, err
+	}
+	return &cursor.BlockNumber, nil
+}
+
+// WriteSnapshot encodes a snapshot in the given format
+func WriteSnapshot(w io.Writer, snapshot *WhitelistSnapshot, format string) error {
+	switch format {
+	case ExportFormatCSV:
+		// Same columns as the import format, so an export can be re-imported
+		writer := csv.NewWriter(w)
+		if err := writer.Write([]string{"address", "allocation", "tier"}); err != nil {
+			return err
+		}
+		for _, row := range snapshot.Entries {
+			if err := writer.Write([]string{row.Address, row.Allocation, row.Tier}); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+
+	case ExportFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(snapshot)
+
+	case ExportFormatMerkle:
+		input := merkleInput{
+			LeafEncoding: []string{"address", "uint256"},
+			Values:       make([][2]string, len(snapshot.Entries)),
+			Block:        snapshot.Block,
+		}
+		hashes := make([]common.Hash, len(snapshot.Entries))
+		// Leaves hold the allocation a proof grants, as in generated roots
+		for i, row := range snapshot.Entries {
+			allocation, _ := new(big.Int).SetString(row.EffectiveAllocation, 10)
+			hash, err := MerkleLeafHash(common.HexToAddress(row.Address), allocation)
+			if err != nil {
+				return err
+			}
+			hashes[i] = hash
+			input.Values[i] = [2]string{row.Address, row.EffectiveAllocation}
+		}
+		if tree, err := NewMerkleTree(hashes); err == nil {
+			input.Root = tree.Root().Hex()
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(input)
+
+	default:
+		return fmt.Errorf("%w %q", ErrUnsupportedFormat, format)
+	}
+}
+
+func (es *ExportService) cursor(ctx context.Context) (*models.IndexerCursor, error) {
+	var cursor models.IndexerCursor
+	if err := es.db.WithContext(ctx).Where("name = ?", chainEventsCursor).First(&cursor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load indexer cursor: %w", err)
+	}
+	return &cursor, nil
+}
+
+// exportRow reports the stored allocation, so a re-import keeps following
+// tier cap changes, next to the effective one
+func exportRow(address string, entry models.WhitelistEntry, limits *allocationLimits) ExportRow {
+	allocation := entry.MaxAllocation
+	if allocation == "" {
+		allocation = "0"
+	}
+	return ExportRow{
+		Address:             address,
+		Allocation:          allocation,
+		EffectiveAllocation: limits.effective(entry).String(),
+		Tier:                entry.Tier,
+	}
+}
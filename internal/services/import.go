@@ -0,0 +1,385 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"whitelist-token-backend/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Import errors returned to handlers
+var (
+	ErrUnsupportedFormat = errors.New("unsupported import format")
+	ErrInvalidImportFile = errors.New("invalid import file")
+)
+
+// Import formats
+const (
+	ImportFormatCSV  = "csv"
+	ImportFormatJSON = "json"
+)
+
+// ImportRow is one valid row of an import file
+type ImportRow struct {
+	Line       int    `json:"line"`
+	Address    string `json:"address"`
+	Allocation string `json:"allocation"`
+	Tier       string `json:"tier,omitempty"`
+}
+
+// ImportIssue describes a row that was skipped
+type ImportIssue struct {
+	Line    int    `json:"line"`
+	Address string `json:"address"`
+	Reason  string `json:"reason"`
+}
+
+// AllocationChange is an address whose allocation or tier differs from the stored entry
+type AllocationChange struct {
+	Address        string `json:"address"`
+	FromAllocation string `json:"from_allocation"`
+	ToAllocation   string `json:"to_allocation"`
+	FromTier       string `json:"from_tier,omitempty"`
+	ToTier         string `json:"to_tier,omitempty"`
+}
+
+// ImportDiff is the effect an import would have on the whitelist
+type ImportDiff struct {
+	Added             []ImportRow        `json:"added"`
+	Removed           []string           `json:"removed"`
+	AllocationChanged []AllocationChange `json:"allocation_changed"`
+	Unchanged         int                `json:"unchanged"`
+}
+
+// ImportOptions controls how an import is applied
+type ImportOptions struct {
+	Format string
+	// Replace treats the file as the complete whitelist, removing addresses not in it
+	Replace bool
+	DryRun  bool
+	Actor   string
+}
+
+// ImportResult is returned for dry runs and applied imports alike
+type ImportResult struct {
+	DryRun     bool          `json:"dry_run"`
+	Rows       int           `json:"rows"`
+	Valid      int           `json:"valid"`
+	Invalid    []ImportIssue `json:"invalid"`
+	Duplicates []ImportIssue `json:"duplicates"`
+	Diff       *ImportDiff   `json:"diff"`
+	BatchJobID uint          `json:"batch_job_id,omitempty"`
+}
+
+// ImportService turns spreadsheet-style whitelist files into whitelist changes
+type ImportService struct {
+	db               *gorm.DB
+	whitelistService *WhitelistService
+	batchService     *BatchService
+	logger           *logrus.Logger
+}
+
+// NewImportService creates a new import service
+func NewImportService(
+	db *gorm.DB,
+	whitelistService *WhitelistService,
+	batchService *BatchService,
+	logger *logrus.Logger,
+) *ImportService {
+	return &ImportService{
+		db:               db,
+		whitelistService: whitelistService,
+		batchService:     batchService,
+		logger:           logger,
+	}
+}
+
+// Import parses and validates a file, diffs it against the stored entries and,
+// unless DryRun is set, applies the diff. Adds and removals are queued as a
+// batch job first; allocation-only changes are then written directly.
+func (is *ImportService) Import(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	rows, total, invalid, err := parseImport(r, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{
+		DryRun:  opts.DryRun,
+		Rows:    total,
+		Invalid: invalid,
+	}
+
+	caps, err := is.whitelistService.tierCaps(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	unique, unknownTiers, duplicates := filterImportRows(rows, caps)
+	result.Invalid = append(result.Invalid, unknownTiers...)
+	result.Duplicates = duplicates
+	result.Valid = len(unique)
+
+	diff, err := is.diff(ctx, unique, opts.Replace)
+	if err != nil {
+		return nil, err
+	}
+	result.Diff = diff
+
+	if opts.DryRun {
+		return result, nil
+	}
+
+	operations := make([]BatchOperation, 0, len(diff.Added)+len(diff.Removed))
+	for _, row := range diff.Added {
+		operations = append(operations, BatchOperation{Address: row.Address, Action: batchActionAdd, MaxAllocation: row.Allocation, Tier: row.Tier})
+	}
+	for _, address := range diff.Removed {
+		operations = append(operations, BatchOperation{Address: address, Action: batchActionRemove})
+	}
+	if len(operations) > 0 {
+		job, _, err := is.batchService.Submit(ctx, operations, opts.Actor)
+		if err != nil {
+			return nil, err
+		}
+		result.BatchJobID = job.ID
+	}
+
+	// Allocations are written once the batch is queued, so a failed submit
+	// leaves the whitelist untouched
+	for _, change := range diff.AllocationChanged {
+		if err := is.whitelistService.UpdateAllocation(ctx, change.Address, change.ToAllocation, change.ToTier); err != nil {
+			if result.BatchJobID != 0 {
+				return nil, fmt.Errorf("batch job %d queued, but updating allocations failed: %w", result.BatchJobID, err)
+			}
+			return nil, err
+		}
+	}
+
+	is.logger.WithFields(logrus.Fields{
+		"actor":              opts.Actor,
+		"added":              len(diff.Added),
+		"removed":            len(diff.Removed),
+		"allocation_changed": len(diff.AllocationChanged),
+		"batch_job_id":       result.BatchJobID,
+	}).Info("Whitelist import applied")
+	return result, nil
+}
+
+// diff compares import rows with the currently whitelisted entries
+func (is *ImportService) diff(ctx context.Context, rows []ImportRow, replace bool) (*ImportDiff, error) {
+	var entries []models.WhitelistEntry
+	if err := is.db.WithContext(ctx).Where("is_whitelisted = ?", true).Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to load whitelist entries: %w", err)
+	}
+	current := make(map[string]models.WhitelistEntry, len(entries))
+	for _, entry := range entries {
+		current[entry.Address] = entry
+	}
+
+	diff := &ImportDiff{
+		Added:             []ImportRow{},
+		Removed:           []string{},
+		AllocationChanged: []AllocationChange{},
+	}
+	inFile := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		inFile[row.Address] = true
+		entry, ok := current[row.Address]
+		if !ok {
+			diff.Added = append(diff.Added, row)
+			continue
+		}
+
+		allocation := entry.MaxAllocation
+		if allocation == "" {
+			allocation = "0"
+		}
+		if allocation != row.Allocation || entry.Tier != row.Tier {
+			diff.AllocationChanged = append(diff.AllocationChanged, AllocationChange{
+				Address:        row.Address,
+				FromAllocation: allocation,
+				ToAllocation:   row.Allocation,
+				FromTier:       entry.Tier,
+				ToTier:         row.Tier,
+			})
+			continue
+		}
+		diff.Unchanged++
+	}
+
+	if replace {
+		for _, entry := range entries {
+			if !inFile[entry.Address] {
+				diff.Removed = append(diff.Removed, entry.Address)
+			}
+		}
+	}
+
+	return diff, nil
+}
+
+// parseImport reads rows from a CSV or JSON file. It returns the valid rows,
+// the total number of rows and the rows that failed validation.
+func parseImport(r io.Reader, format string) ([]ImportRow, int, []ImportIssue, error) {
+	var raw []ImportRow
+	switch strings.ToLower(format) {
+	case ImportFormatCSV:
+		rows, err := readImportCSV(r)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		raw = rows
+	case ImportFormatJSON:
+		var records []struct {
+			Address       string          `json:"address"`
+			Allocation    json.RawMessage `json:"allocation"`
+			MaxAllocation json.RawMessage `json:"max_allocation"`
+			Tier          string          `json:"tier"`
+		}
+		if err := json.NewDecoder(r).Decode(&records); err != nil {
+			return nil, 0, nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+		}
+		for i, record := range records {
+			// A missing or null allocation means no cap
+			allocation := jsonAllocation(record.Allocation)
+			if allocation == "" {
+				allocation = jsonAllocation(record.MaxAllocation)
+			}
+			raw = append(raw, ImportRow{
+				Line:       i + 1,
+				Address:    record.Address,
+				Allocation: allocation,
+				Tier:       record.Tier,
+			})
+		}
+	default:
+		return nil, 0, nil, fmt.Errorf("%w %q", ErrUnsupportedFormat, format)
+	}
+
+	valid := make([]ImportRow, 0, len(raw))
+	invalid := []ImportIssue{}
+	for _, row := range raw {
+		address, err := NormalizeAddress(strings.TrimSpace(row.Address))
+		if err != nil {
+			invalid = append(invalid, ImportIssue{Line: row.Line, Address: row.Address, Reason: err.Error()})
+			continue
+		}
+		allocation, err := parseAllocation(strings.TrimSpace(row.Allocation))
+		if err != nil {
+			invalid = append(invalid, ImportIssue{Line: row.Line, Address: row.Address, Reason: err.Error()})
+			continue
+		}
+		valid = append(valid, ImportRow{
+			Line:       row.Line,
+			Address:    address,
+			Allocation: allocation,
+			Tier:       strings.ToLower(strings.TrimSpace(row.Tier)),
+		})
+	}
+
+	return valid, len(raw), invalid, nil
+}
+
+// filterImportRows drops rows with an unknown tier and repeats of an address,
+// keeping the first row of each address
+func filterImportRows(rows []ImportRow, caps map[string]*big.Int) ([]ImportRow, []ImportIssue, []ImportIssue) {
+	unique := make([]ImportRow, 0, len(rows))
+	invalid := []ImportIssue{}
+	duplicates := []ImportIssue{}
+	firstLine := make(map[string]int, len(rows))
+	for _, row := range rows {
+		if _, ok := caps[row.Tier]; row.Tier != "" && !ok {
+			invalid = append(invalid, ImportIssue{
+				Line:    row.Line,
+				Address: row.Address,
+				Reason:  fmt.Sprintf("unknown tier %q", row.Tier),
+			})
+			continue
+		}
+		if line, ok := firstLine[row.Address]; ok {
+			duplicates = append(duplicates, ImportIssue{
+				Line:    row.Line,
+				Address: row.Address,
+				Reason:  fmt.Sprintf("duplicate of line %d", line),
+			})
+			continue
+		}
+		firstLine[row.Address] = row.Line
+		unique = append(unique, row)
+	}
+	return unique, invalid, duplicates
+}
+
+// jsonAllocation returns an allocation given as a JSON number or string, and
+// an empty string when it is missing or null
+func jsonAllocation(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	return strings.Trim(string(raw), `"`)
+}
+
+// readImportCSV reads a CSV with an address column and optional allocation and
+// tier columns. Line numbers count the header as line 1, as spreadsheets do.
+func readImportCSV(r io.Reader) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing header: %v", ErrInvalidImportFile, err)
+	}
+	columns := map[string]int{"address": -1, "allocation": -1, "tier": -1}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if name == "max_allocation" {
+			name = "allocation"
+		}
+		if _, ok := columns[name]; ok {
+			columns[name] = i
+		}
+	}
+	if columns["address"] < 0 {
+		return nil, fmt.Errorf("%w: missing address column", ErrInvalidImportFile)
+	}
+
+	field := func(record []string, column string) string {
+		if i := columns[column]; i >= 0 && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	var rows []ImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		// The reader skips empty lines, so take the line from the file
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, ImportRow{
+			Line:       line,
+			Address:    field(record, "address"),
+			Allocation: field(record, "allocation"),
+			Tier:       field(record, "tier"),
+		})
+	}
+	return rows, nil
+}
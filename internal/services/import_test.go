@@ -0,0 +1,152 @@
+package services
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	importAddressA = "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"
+	importAddressB = "0x70997970C51812dc3A010C7d01b50e0d17dc79C8"
+)
+
+func TestParseImport(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		input       string
+		wantRows    []ImportRow
+		wantTotal   int
+		wantInvalid []ImportIssue
+		wantErr     error
+	}{
+		{
+			name:   "CSV with BOM header and max_allocation column",
+			format: "CSV",
+			input:  "\ufeffAddress,Max_Allocation,Tier\n" + importAddressA + ",1000, Gold \n",
+			wantRows: []ImportRow{
+				{Line: 2, Address: importAddressA, Allocation: "1000", Tier: "gold"},
+			},
+			wantTotal:   1,
+			wantInvalid: []ImportIssue{},
+		},
+		{
+			name:   "CSV without allocation column and blank lines",
+			format: ImportFormatCSV,
+			input:  "address\n" + strings.ToLower(importAddressA) + "\n\n" + importAddressB + "\n",
+			wantRows: []ImportRow{
+				{Line: 2, Address: importAddressA, Allocation: "0"},
+				{Line: 4, Address: importAddressB, Allocation: "0"},
+			},
+			wantTotal:   2,
+			wantInvalid: []ImportIssue{},
+		},
+		{
+			name:   "CSV with bad checksum and bad allocation",
+			format: ImportFormatCSV,
+			input: "address,allocation\n" +
+				"0xF39Fd6e51aad88F6F4ce6aB8827279cffFb92266,10\n" +
+				importAddressB + ",-5\n" +
+				importAddressA + ",\n",
+			wantRows: []ImportRow{
+				{Line: 4, Address: importAddressA, Allocation: "0"},
+			},
+			wantTotal: 3,
+			wantInvalid: []ImportIssue{
+				{Line: 2, Address: "0xF39Fd6e51aad88F6F4ce6aB8827279cffFb92266", Reason: ErrInvalidAddress.Error()},
+				{Line: 3, Address: importAddressB, Reason: ErrInvalidAllocation.Error() + `: "-5"`},
+			},
+		},
+		{
+			name:    "CSV without address column",
+			format:  ImportFormatCSV,
+			input:   "wallet,allocation\n" + importAddressA + ",10\n",
+			wantErr: ErrInvalidImportFile,
+		},
+		{
+			name:   "JSON with null, missing, string and numeric allocations",
+			format: ImportFormatJSON,
+			input: `[
+				{"address": "` + importAddressA + `", "allocation": null, "max_allocation": "7"},
+				{"address": "` + importAddressB + `"},
+				{"address": "` + strings.ToLower(importAddressA) + `", "allocation": "20", "tier": "Silver"},
+				{"address": "` + strings.ToLower(importAddressB) + `", "allocation": 30}
+			]`,
+			wantRows: []ImportRow{
+				{Line: 1, Address: importAddressA, Allocation: "7"},
+				{Line: 2, Address: importAddressB, Allocation: "0"},
+				{Line: 3, Address: importAddressA, Allocation: "20", Tier: "silver"},
+				{Line: 4, Address: importAddressB, Allocation: "30"},
+			},
+			wantTotal:   4,
+			wantInvalid: []ImportIssue{},
+		},
+		{
+			name:    "malformed JSON",
+			format:  ImportFormatJSON,
+			input:   `{"address": "` + importAddressA + `"}`,
+			wantErr: ErrInvalidImportFile,
+		},
+		{
+			name:    "unsupported format",
+			format:  "xlsx",
+			input:   "address\n" + importAddressA + "\n",
+			wantErr: ErrUnsupportedFormat,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, total, invalid, err := parseImport(strings.NewReader(tt.input), tt.format)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("parseImport() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseImport() error = %v", err)
+			}
+			if !reflect.DeepEqual(rows, tt.wantRows) {
+				t.Errorf("rows = %+v, want %+v", rows, tt.wantRows)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", total, tt.wantTotal)
+			}
+			if !reflect.DeepEqual(invalid, tt.wantInvalid) {
+				t.Errorf("invalid = %+v, want %+v", invalid, tt.wantInvalid)
+			}
+		})
+	}
+}
+
+func TestFilterImportRows(t *testing.T) {
+	rows := []ImportRow{
+		{Line: 2, Address: importAddressA, Allocation: "10"},
+		{Line: 3, Address: importAddressB, Allocation: "0", Tier: "gold"},
+		{Line: 4, Address: importAddressA, Allocation: "20"},
+		{Line: 5, Address: importAddressB, Allocation: "0", Tier: "platinum"},
+		{Line: 6, Address: importAddressB, Allocation: "5"},
+	}
+	caps := map[string]*big.Int{"gold": big.NewInt(100)}
+
+	unique, invalid, duplicates := filterImportRows(rows, caps)
+
+	wantUnique := []ImportRow{rows[0], rows[1]}
+	wantInvalid := []ImportIssue{{Line: 5, Address: importAddressB, Reason: `unknown tier "platinum"`}}
+	wantDuplicates := []ImportIssue{
+		{Line: 4, Address: importAddressA, Reason: "duplicate of line 2"},
+		{Line: 6, Address: importAddressB, Reason: "duplicate of line 3"},
+	}
+	if !reflect.DeepEqual(unique, wantUnique) {
+		t.Errorf("unique = %+v, want %+v", unique, wantUnique)
+	}
+	if !reflect.DeepEqual(invalid, wantInvalid) {
+		t.Errorf("invalid = %+v, want %+v", invalid, wantInvalid)
+	}
+	if !reflect.DeepEqual(duplicates, wantDuplicates) {
+		t.Errorf("duplicates = %+v, want %+v", duplicates, wantDuplicates)
+	}
+}
@@ -0,0 +1,905 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"whitelist-token-backend/internal/models"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	chainEventsCursor = "chain_events"
+	indexerLockKey    = "lock:chain:indexer"
+	indexerLockTTL    = 5 * time.Minute
+	// indexerPassDuration bounds one locked pass well within the lock TTL;
+	// a long backfill continues in the next pass
+	indexerPassDuration = indexerLockTTL / 2
+	// indexerActor is recorded on whitelist entries changed by transactions
+	// this service did not send
+	indexerActor = "indexer"
+	// reorgTrackingDepth is how many blocks below the head keep their hash
+	// for reorg detection
+	reorgTrackingDepth = 128
+	// undecodedEventName marks chain events stored as raw logs because they
+	// could not be decoded
+	undecodedEventName = "undecoded"
+	// Rate-limited eth_getLogs calls are retried after a growing delay
+	indexerRetryDelay    = time.Second
+	indexerMaxRetryDelay = 30 * time.Second
+)
+
+// errReorgDuringRange aborts a block range whose blocks changed while it was
+// being fetched; the next pass finds the fork and rolls back
+var errReorgDuringRange = errors.New("chain reorganized while indexing a block range")
+
+// chainActivityActions are the activity log actions written by the indexer
+var chainActivityActions = []string{
+	"purchase", "claim", "transfer", "sale_paused", "sale_unpaused", "whitelist_added", "whitelist_removed",
+}
+
+// IndexerConfig controls the chain event indexer
+type IndexerConfig struct {
+	DeployBlock   uint64 // First block scanned for contract events
+	PollInterval  time.Duration
+	MaxRange      uint64 // Largest block range per eth_getLogs request
+	Confirmations uint64 // Blocks required on top of a purchase before it is confirmed
+}
+
+// IndexerService follows the sale and token contracts' events and mirrors
+// them into purchases, whitelist entries and activity logs. Every event is
+// stored in chain_events keyed by tx hash and log index, so a block range
+// can be processed again without duplicating rows.
+type IndexerService struct {
+	db                *gorm.DB
+	redis             *redis.Client
+	blockchainService *BlockchainService
+	whitelistService  *WhitelistService
+	config            IndexerConfig
+	logger            *logrus.Logger
+
+	// rangeSize adapts to the RPC provider's eth_getLogs limits
+	rangeSize uint64
+}
+
+// NewIndexerService creates a new indexer service
+func NewIndexerService(
+	db *gorm.DB,
+	redis *redis.Client,
+	blockchainService *BlockchainService,
+	whitelistService *WhitelistService,
+	config IndexerConfig,
+	logger *logrus.Logger,
+) *IndexerService {
+	if config.PollInterval <= 0 {
+		config.PollInterval = 15 * time.Second
+	}
+	if config.MaxRange == 0 {
+		config.MaxRange = 2000
+	}
+	return &IndexerService{
+		db:                db,
+		redis:             redis,
+		blockchainService: blockchainService,
+		whitelistService:  whitelistService,
+		config:            config,
+		logger:            logger,
+		rangeSize:         config.MaxRange,
+	}
+}
+
+// Start backfills from the cursor, or the deploy block on an empty
+// database, up to the chain head and then follows new blocks until ctx is
+// cancelled. New heads come from a subscription when the RPC endpoint
+// supports one; otherwise, and while the subscription is down, the chain is
+// polled every PollInterval. Either way each new block is indexed from the
+// cursor, so the switch from backfill to live following leaves no gap.
+func (is *IndexerService) Start(ctx context.Context) {
+	ticker := time.NewTicker(is.config.PollInterval)
+	defer ticker.Stop()
+
+	heads := make(chan *types.Header, 16)
+	var sub ethereum.Subscription
+	defer func() {
+		if sub != nil {
+			sub.Unsubscribe()
+		}
+	}()
+
+	polling := false
+	caughtUp := false
+	for {
+		if sub == nil {
+			var err error
+			sub, err = is.blockchainService.SubscribeNewHead(ctx, heads)
+			switch {
+			case err == nil:
+				if polling {
+					is.logger.Info("Indexer subscribed to new blocks")
+				}
+				polling = false
+			case !polling && ctx.Err() == nil:
+				is.logger.WithError(err).Infof("Indexer polling every %s, new block subscription unavailable", is.config.PollInterval)
+				polling = true
+			}
+		}
+
+		err := is.Sync(ctx)
+		if err != nil && !errors.Is(err, ErrLockHeld) && ctx.Err() == nil {
+			is.logger.WithError(err).Error("Failed to index contract events")
+		}
+		if err == nil && !caughtUp {
+			caughtUp = true
+			is.logger.Info("Indexer caught up with the chain head, following new blocks")
+		}
+
+		var subErr <-chan error
+		if sub != nil {
+			subErr = sub.Err()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-heads:
+		case err := <-subErr:
+			is.logger.WithError(err).Warn("New block subscription dropped")
+			sub.Unsubscribe()
+			sub = nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync indexes events from the block after the cursor up to the chain head.
+// Each block range is applied in one transaction together with the cursor,
+// so an interrupted sync resumes where it stopped. Long backfills are split
+// into passes that each hold the indexer lock for a bounded time.
+func (is *IndexerService) Sync(ctx context.Context) error {
+	for {
+		done, err := is.syncPass(ctx)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// syncPass indexes block ranges under the indexer lock until the head is
+// reached, reported as done, or the pass runs out of time. A pass first
+// checks that the last indexed block is still on the chain and rolls back to
+// the fork point if it is not.
+func (is *IndexerService) syncPass(ctx context.Context) (bool, error) {
+	release, err := acquireLock(ctx, is.redis, indexerLockKey, indexerLockTTL)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+	deadline := time.Now().Add(indexerPassDuration)
+
+	head, err := is.blockchainService.BlockNumber(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get block number: %w", err)
+	}
+
+	from := is.config.DeployBlock
+	cursor, err := is.cursor(ctx)
+	if err != nil {
+		return false, err
+	}
+	if cursor != nil {
+		from = cursor.BlockNumber + 1
+		fork, err := is.findFork(ctx, cursor.BlockNumber)
+		if err != nil {
+			return false, err
+		}
+		if fork != nil {
+			if err := is.rollback(ctx, cursor.BlockNumber, *fork); err != nil {
+				return false, err
+			}
+			from = *fork + 1
+		}
+	}
+	if head > from && head-from > is.rangeSize {
+		is.logger.WithFields(logrus.Fields{
+			"from": from,
+			"head": head,
+		}).Info("Backfilling contract events")
+	}
+
+	done := true
+	retryDelay := indexerRetryDelay
+	for from <= head {
+		if time.Now().After(deadline) {
+			done = false
+			break
+		}
+
+		to := min(from+is.rangeSize-1, head)
+		logs, err := is.blockchainService.FilterContractLogs(ctx, from, to)
+		if err != nil {
+			if ctx.Err() == nil && isRateLimited(err) && time.Now().Add(retryDelay).Before(deadline) {
+				is.logger.WithError(err).WithField("retry_in", retryDelay).Warn("Indexer rate limited by RPC provider")
+				select {
+				case <-ctx.Done():
+					return false, ctx.Err()
+				case <-time.After(retryDelay):
+				}
+				retryDelay = min(retryDelay*2, indexerMaxRetryDelay)
+				continue
+			}
+			if ctx.Err() == nil && isRangeTooLarge(err) && is.rangeSize > 1 {
+				is.rangeSize /= 2
+				is.logger.WithError(err).WithField("range", is.rangeSize).Debug("Shrinking indexer block range")
+				continue
+			}
+			return false, err
+		}
+		retryDelay = indexerRetryDelay
+
+		if err := is.indexRange(ctx, from, to, head, logs); err != nil {
+			return false, err
+		}
+
+		if len(logs) > 0 {
+			is.logger.WithFields(logrus.Fields{
+				"from":   from,
+				"to":     to,
+				"events": len(logs),
+			}).Debug("Indexed contract events")
+		}
+		from = to + 1
+		// Grow back towards MaxRange after the provider accepted a range
+		is.rangeSize = min(is.rangeSize*2, is.config.MaxRange)
+	}
+
+	if err := is.confirmPurchases(ctx, head); err != nil {
+		return false, err
+	}
+	if head > reorgTrackingDepth {
+		if err := is.db.WithContext(ctx).Where("number < ?", head-reorgTrackingDepth).Delete(&models.IndexedBlock{}).Error; err != nil {
+			return false, fmt.Errorf("failed to prune indexed blocks: %w", err)
+		}
+	}
+	return done, nil
+}
+
+// indexRange applies the logs of one block range and advances the cursor to
+// to. Blocks within reorgTrackingDepth of the head have their hashes stored,
+// and logs from those blocks must match them.
+func (is *IndexerService) indexRange(ctx context.Context, from, to, head uint64, logs []types.Log) error {
+	blockTimes := make(map[uint64]time.Time)
+	blockTime := func(number uint64) (time.Time, error) {
+		if t, ok := blockTimes[number]; ok {
+			return t, nil
+		}
+		header, err := is.blockchainService.HeaderByNumber(ctx, number)
+		if err != nil {
+			return time.Time{}, err
+		}
+		blockTimes[number] = time.Unix(int64(header.Time), 0)
+		return blockTimes[number], nil
+	}
+
+	trackFrom := uint64(0)
+	if head > reorgTrackingDepth {
+		trackFrom = head - reorgTrackingDepth
+	}
+	var tracked []models.IndexedBlock
+	hashes := make(map[uint64]string)
+	for number := max(from, trackFrom); number <= to; number++ {
+		header, err := is.blockchainService.HeaderByNumber(ctx, number)
+		if err != nil {
+			return err
+		}
+		parent, ok := hashes[number-1]
+		if !ok && number > 0 {
+			var stored models.IndexedBlock
+			if err := is.db.WithContext(ctx).Where("number = ?", number-1).Limit(1).Find(&stored).Error; err != nil {
+				return fmt.Errorf("failed to load indexed block: %w", err)
+			}
+			parent = stored.Hash
+		}
+		if parent != "" && header.ParentHash.Hex() != parent {
+			return errReorgDuringRange
+		}
+
+		hashes[number] = header.Hash().Hex()
+		blockTimes[number] = time.Unix(int64(header.Time), 0)
+		tracked = append(tracked, models.IndexedBlock{
+			Number:     number,
+			Hash:       header.Hash().Hex(),
+			ParentHash: header.ParentHash.Hex(),
+		})
+	}
+	for _, vLog := range logs {
+		if hash, ok := hashes[vLog.BlockNumber]; ok && vLog.BlockHash.Hex() != hash {
+			return errReorgDuringRange
+		}
+	}
+
+	return is.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, vLog := range logs {
+			if vLog.Removed {
+				continue
+			}
+			event, err := is.blockchainService.DecodeContractLog(vLog)
+			if err != nil {
+				// Kept as is, so it can be replayed once the ABI is fixed
+				stored, storeErr := storeUndecodedLog(tx, vLog, err)
+				if storeErr != nil {
+					return storeErr
+				}
+				if stored {
+					is.logger.WithError(err).WithFields(logrus.Fields{
+						"tx_hash":   vLog.TxHash.Hex(),
+						"log_index": vLog.Index,
+					}).Warn("Stored undecodable contract event")
+				}
+				continue
+			}
+
+			stored, err := storeChainEvent(tx, event)
+			if err != nil {
+				return err
+			}
+			if !stored {
+				continue // Indexed by an earlier run
+			}
+
+			switch args := event.Args.(type) {
+			case *TokenPurchaseEvent:
+				err = is.applyPurchase(tx, event, args)
+			case *TokensClaimedEvent:
+				err = is.applyClaim(tx, event, args, blockTime)
+			case *PauseEvent:
+				err = is.applyPause(tx, event, args)
+			case *TransferEvent:
+				err = is.applyTransfer(tx, event, args)
+			case *WhitelistUpdatedEvent:
+				err = is.applyWhitelistUpdate(tx, event, args, blockTime)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to apply %s event in tx %s: %w", event.Name, event.TxHash.Hex(), err)
+			}
+		}
+
+		if len(tracked) > 0 {
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&tracked).Error; err != nil {
+				return fmt.Errorf("failed to store indexed blocks: %w", err)
+			}
+		}
+		cursor := models.IndexerCursor{Name: chainEventsCursor, BlockNumber: to}
+		if err := tx.Save(&cursor).Error; err != nil {
+			return fmt.Errorf("failed to store indexer cursor: %w", err)
+		}
+		return nil
+	})
+}
+
+// findFork compares the stored hashes of recent blocks, starting at the
+// cursor, with the chain. It returns nil when the cursor block is still
+// canonical, otherwise the highest block both agree on. When no tracked
+// block matches, the fork is placed just below the oldest one.
+func (is *IndexerService) findFork(ctx context.Context, cursorBlock uint64) (*uint64, error) {
+	var blocks []models.IndexedBlock
+	err := is.db.WithContext(ctx).Where("number <= ?", cursorBlock).
+		Order("number DESC").
+		Limit(reorgTrackingDepth).
+		Find(&blocks).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load indexed blocks: %w", err)
+	}
+	// Blocks indexed far below the head during a backfill are not tracked
+	if len(blocks) == 0 || blocks[0].Number != cursorBlock {
+		return nil, nil
+	}
+
+	for i, block := range blocks {
+		header, err := is.blockchainService.HeaderByNumber(ctx, block.Number)
+		if err != nil && !errors.Is(err, ethereum.NotFound) {
+			return nil, err
+		}
+		if err == nil && header.Hash().Hex() == block.Hash {
+			if i == 0 {
+				return nil, nil
+			}
+			fork := block.Number
+			return &fork, nil
+		}
+	}
+
+	fork := uint64(0)
+	if oldest := blocks[len(blocks)-1].Number; oldest > 0 {
+		fork = oldest - 1
+	}
+	return &fork, nil
+}
+
+// rollback removes everything derived from events above the fork block and
+// moves the cursor back to it, so the canonical chain is indexed again.
+// Whitelist entries last changed in an orphaned block return to pending
+// until their transaction is mined again.
+func (is *IndexerService) rollback(ctx context.Context, cursorBlock, fork uint64) error {
+	var buyers []string
+	var orphaned []models.ChainEvent
+	err := is.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("block_number > ?", fork).Find(&orphaned).Error; err != nil {
+			return fmt.Errorf("failed to load orphaned events: %w", err)
+		}
+		var txHashes, claimTxHashes []string
+		for _, event := range orphaned {
+			txHashes = append(txHashes, event.TxHash)
+			if event.Name == EventTokensClaimed {
+				claimTxHashes = append(claimTxHashes, event.TxHash)
+			}
+		}
+
+		err := tx.Model(&models.Purchase{}).Where("block_number > ?", fork).Distinct("buyer_address").Pluck("buyer_address", &buyers).Error
+		if err != nil {
+			return fmt.Errorf("failed to load orphaned purchases: %w", err)
+		}
+		// Purchases are deleted outright so re-indexing can store them again under the same log key
+		if err := tx.Unscoped().Where("block_number > ?", fork).Delete(&models.Purchase{}).Error; err != nil {
+			return fmt.Errorf("failed to delete orphaned purchases: %w", err)
+		}
+		if len(claimTxHashes) > 0 {
+			err := tx.Model(&models.Purchase{}).Where("claim_tx_hash IN ?", claimTxHashes).
+				Updates(map[string]interface{}{"claim_status": "unclaimed", "claimed_at": nil, "claim_tx_hash": ""}).Error
+			if err != nil {
+				return fmt.Errorf("failed to revert orphaned claims: %w", err)
+			}
+		}
+
+		if err := tx.Where("block_number > ?", fork).Delete(&models.WhitelistEvent{}).Error; err != nil {
+			return fmt.Errorf("failed to delete orphaned whitelist events: %w", err)
+		}
+		if err := restoreWhitelistEntries(tx, fork); err != nil {
+			return err
+		}
+
+		if len(txHashes) > 0 {
+			if err := tx.Where("tx_hash IN ? AND action IN ?", txHashes, chainActivityActions).Delete(&models.ActivityLog{}).Error; err != nil {
+				return fmt.Errorf("failed to delete orphaned activity logs: %w", err)
+			}
+		}
+
+		// Restore the paused flag from the last pause event still on the chain
+		var last models.ChainEvent
+		err = tx.Where("block_number <= ? AND name IN ?", fork, []string{EventPaused, EventUnpaused}).
+			Order("block_number DESC, log_index DESC").
+			First(&last).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load pause events: %w", err)
+		}
+		if err == nil {
+			if err := tx.Model(&models.SaleConfig{}).Where("is_active = ?", true).Update("is_paused", last.Name == EventPaused).Error; err != nil {
+				return fmt.Errorf("failed to update sale config: %w", err)
+			}
+		}
+
+		if err := tx.Where("block_number > ?", fork).Delete(&models.ChainEvent{}).Error; err != nil {
+			return fmt.Errorf("failed to delete orphaned events: %w", err)
+		}
+		if err := tx.Where("number > ?", fork).Delete(&models.IndexedBlock{}).Error; err != nil {
+			return fmt.Errorf("failed to delete orphaned blocks: %w", err)
+		}
+		cursor := models.IndexerCursor{Name: chainEventsCursor, BlockNumber: fork}
+		if err := tx.Save(&cursor).Error; err != nil {
+			return fmt.Errorf("failed to store indexer cursor: %w", err)
+		}
+
+		details, _ := json.Marshal(map[string]interface{}{
+			"fork_block":      fork,
+			"indexed_block":   cursorBlock,
+			"orphaned_events": len(orphaned),
+		})
+		return tx.Create(&models.SystemLog{
+			Level:     "warning",
+			Component: "blockchain",
+			Message:   "Chain reorganization rolled back indexed events",
+			Details:   string(details),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(buyers) > 0 {
+		if _, err := is.whitelistService.SyncUsedAllocation(ctx, buyers...); err != nil {
+			return err
+		}
+	}
+
+	is.logger.WithFields(logrus.Fields{
+		"fork_block":      fork,
+		"depth":           cursorBlock - fork,
+		"orphaned_events": len(orphaned),
+	}).Warn("Chain reorganization detected, re-indexing from fork block")
+	return nil
+}
+
+// restoreWhitelistEntries puts entries last changed above the fork block back
+// to the state of their last whitelist event still on the chain. Entries the
+// indexer created for an orphaned event are deleted; those written for our own
+// transactions wait as pending for it to be mined again.
+func restoreWhitelistEntries(tx *gorm.DB, fork uint64) error {
+	var entries []models.WhitelistEntry
+	if err := tx.Unscoped().Where("block_number > ?", fork).Find(&entries).Error; err != nil {
+		return fmt.Errorf("failed to load orphaned whitelist entries: %w", err)
+	}
+
+	for _, entry := range entries {
+		var last models.WhitelistEvent
+		err := tx.Where("address = ? AND block_number <= ?", entry.Address, fork).
+			Order("block_number DESC, log_index DESC").
+			First(&last).Error
+		switch {
+		case err == nil:
+			err = tx.Unscoped().Model(&entry).Updates(map[string]interface{}{
+				"is_whitelisted": last.Whitelisted,
+				"status":         models.WhitelistStatusConfirmed,
+				"tx_hash":        last.TxHash,
+				"block_number":   last.BlockNumber,
+				"deleted_at":     nil,
+			}).Error
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return fmt.Errorf("failed to load whitelist events: %w", err)
+		case entry.AddedBy == indexerActor:
+			err = tx.Unscoped().Delete(&entry).Error
+		default:
+			err = tx.Unscoped().Model(&entry).
+				Updates(map[string]interface{}{"status": models.WhitelistStatusPending, "block_number": 0}).Error
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore whitelist entry %s: %w", entry.Address, err)
+		}
+	}
+	return nil
+}
+
+// confirmPurchases confirms pending purchases with enough blocks on top of
+// them and refreshes their buyers' used allocation
+func (is *IndexerService) confirmPurchases(ctx context.Context, head uint64) error {
+	if head < is.config.Confirmations {
+		return nil
+	}
+	confirmedBlock := head - is.config.Confirmations
+
+	var buyers []string
+	err := is.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&models.Purchase{}).Where("status = ? AND block_number <= ?", models.PurchaseStatusPending, confirmedBlock)
+		if err := query.Distinct("buyer_address").Pluck("buyer_address", &buyers).Error; err != nil {
+			return fmt.Errorf("failed to load pending purchases: %w", err)
+		}
+		if len(buyers) == 0 {
+			return nil
+		}
+		err := tx.Model(&models.Purchase{}).Where("status = ? AND block_number <= ?", models.PurchaseStatusPending, confirmedBlock).
+			Update("status", models.PurchaseStatusConfirmed).Error
+		if err != nil {
+			return fmt.Errorf("failed to confirm purchases: %w", err)
+		}
+		return nil
+	})
+	if err != nil || len(buyers) == 0 {
+		return err
+	}
+
+	_, err = is.whitelistService.SyncUsedAllocation(ctx, buyers...)
+	return err
+}
+
+// applyPurchase records a TokenPurchase as a pending purchase, confirmed once
+// it has enough confirmations
+func (is *IndexerService) applyPurchase(tx *gorm.DB, event *ContractEvent, args *TokenPurchaseEvent) error {
+	buyer := args.Buyer.Hex()
+	user, err := findOrCreateUser(tx, buyer)
+	if err != nil {
+		return err
+	}
+
+	// Price in wei per whole token, assuming 18 decimals
+	price := new(big.Int)
+	if args.TokenAmount.Sign() > 0 {
+		price.Mul(args.EthAmount, big.NewInt(1e18)).Div(price, args.TokenAmount)
+	}
+
+	purchase := models.Purchase{
+		UserID:         user.ID,
+		BuyerAddress:   buyer,
+		TokenAmount:    args.TokenAmount.String(),
+		EthAmount:      args.EthAmount.String(),
+		TokenPrice:     price.String(),
+		TxHash:         event.TxHash.Hex(),
+		LogIndex:       event.LogIndex,
+		BlockNumber:    event.BlockNumber,
+		BlockTimestamp: time.Unix(args.Timestamp.Int64(), 0),
+		Status:         models.PurchaseStatusPending,
+	}
+	if err := tx.Create(&purchase).Error; err != nil {
+		return fmt.Errorf("failed to store purchase: %w", err)
+	}
+
+	return logChainActivity(tx, buyer, "purchase", event, map[string]string{
+		"token_amount": purchase.TokenAmount,
+		"eth_amount":   purchase.EthAmount,
+	})
+}
+
+// applyClaim marks the claimant's purchases up to the event's block as claimed
+func (is *IndexerService) applyClaim(tx *gorm.DB, event *ContractEvent, args *TokensClaimedEvent, blockTime func(uint64) (time.Time, error)) error {
+	user := args.User.Hex()
+	claimedAt, err := blockTime(event.BlockNumber)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Model(&models.Purchase{}).
+		Where("buyer_address = ? AND claim_status = ? AND block_number <= ?", user, "unclaimed", event.BlockNumber).
+		Updates(map[string]interface{}{
+			"claim_status":  "claimed",
+			"claimed_at":    claimedAt,
+			"claim_tx_hash": event.TxHash.Hex(),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update claimed purchases: %w", err)
+	}
+
+	return logChainActivity(tx, user, "claim", event, map[string]string{"amount": args.Amount.String()})
+}
+
+// applyPause mirrors the sale contract's paused state into the active sale config
+func (is *IndexerService) applyPause(tx *gorm.DB, event *ContractEvent, args *PauseEvent) error {
+	paused := event.Name == EventPaused
+	err := tx.Model(&models.SaleConfig{}).Where("is_active = ?", true).Update("is_paused", paused).Error
+	if err != nil {
+		return fmt.Errorf("failed to update sale config: %w", err)
+	}
+
+	action := "sale_unpaused"
+	if paused {
+		action = "sale_paused"
+	}
+	return logChainActivity(tx, args.Account.Hex(), action, event, nil)
+}
+
+// applyTransfer logs a token transfer for each party other than the zero
+// address, so mints and burns show up once
+func (is *IndexerService) applyTransfer(tx *gorm.DB, event *ContractEvent, args *TransferEvent) error {
+	details := map[string]string{"from": args.From.Hex(), "to": args.To.Hex(), "value": args.Value.String()}
+	for _, address := range []common.Address{args.From, args.To} {
+		if address == (common.Address{}) {
+			continue
+		}
+		if err := logChainActivity(tx, address.Hex(), "transfer", event, details); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyWhitelistUpdate stores the event for historical snapshots and brings
+// the whitelist entry in line with the chain. Entries whose own transaction is
+// still pending are left to the whitelist service, unless this is that
+// transaction.
+func (is *IndexerService) applyWhitelistUpdate(tx *gorm.DB, event *ContractEvent, args *WhitelistUpdatedEvent, blockTime func(uint64) (time.Time, error)) error {
+	address := args.User.Hex()
+	whitelisted := args.Status
+	txHash := event.TxHash.Hex()
+
+	record := models.WhitelistEvent{
+		Address:     address,
+		Whitelisted: whitelisted,
+		BlockNumber: event.BlockNumber,
+		TxHash:      txHash,
+		LogIndex:    event.LogIndex,
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record).Error; err != nil {
+		return fmt.Errorf("failed to store whitelist event: %w", err)
+	}
+
+	var entry models.WhitelistEntry
+	err := tx.Unscoped().Where("address = ?", address).First(&entry).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to load whitelist entry: %w", err)
+	}
+
+	switch {
+	case entry.ID == 0 && !whitelisted:
+		return nil
+	case entry.ID != 0 && entry.Status == models.WhitelistStatusPending && entry.TxHash != txHash:
+		return nil
+	case entry.ID != 0 && entry.BlockNumber > event.BlockNumber:
+		return nil
+	}
+
+	changed := entry.ID == 0 || entry.IsWhitelisted != whitelisted || entry.DeletedAt.Valid
+	if entry.ID == 0 {
+		user, err := findOrCreateUser(tx, address)
+		if err != nil {
+			return err
+		}
+		entry.UserID = user.ID
+		entry.Address = address
+		entry.MaxAllocation = "0"
+		entry.UsedAllocation = "0"
+	}
+	if changed {
+		at, err := blockTime(event.BlockNumber)
+		if err != nil {
+			return err
+		}
+		if whitelisted {
+			entry.AddedBy = indexerActor
+			entry.AddedAt = at
+			entry.RemovedBy = ""
+			entry.RemovedAt = nil
+		} else {
+			entry.RemovedBy = indexerActor
+			entry.RemovedAt = &at
+		}
+	}
+	entry.IsWhitelisted = whitelisted
+	entry.Status = models.WhitelistStatusConfirmed
+	entry.TxHash = txHash
+	entry.BlockNumber = event.BlockNumber
+	entry.DeletedAt = gorm.DeletedAt{}
+	if err := tx.Unscoped().Save(&entry).Error; err != nil {
+		return fmt.Errorf("failed to save whitelist entry: %w", err)
+	}
+
+	if !changed {
+		return nil
+	}
+	action := "whitelist_removed"
+	if whitelisted {
+		action = "whitelist_added"
+	}
+	return logChainActivity(tx, address, action, event, nil)
+}
+
+func (is *IndexerService) cursor(ctx context.Context) (*models.IndexerCursor, error) {
+	var cursor models.IndexerCursor
+	if err := is.db.WithContext(ctx).Where("name = ?", chainEventsCursor).First(&cursor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load indexer cursor: %w", err)
+	}
+	return &cursor, nil
+}
+
+// isRangeTooLarge reports whether an eth_getLogs error is a provider limit on
+// the block range or result size, which a smaller range avoids
+func isRangeTooLarge(err error) bool {
+	if isRateLimited(err) {
+		return false
+	}
+	message := strings.ToLower(err.Error())
+	for _, pattern := range []string{
+		"query returned more than", // Infura, Geth-based providers
+		"response size",            // Alchemy
+		"block range",              // Ankr, Alchemy, BSC
+		"range is too large",
+		"too many results",
+		"too many blocks",
+		"exceed maximum block range",
+		"logs matched by query exceeds limit",
+	} {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRateLimited reports whether an RPC call was refused by the provider's rate
+// limit, which waiting rather than a smaller range avoids
+func isRateLimited(err error) bool {
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	for _, pattern := range []string{
+		"too many requests",
+		"rate limit",
+		"exceeded its compute units", // Alchemy
+		"request limit",
+	} {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// storeChainEvent records an event and reports whether it was new
+func storeChainEvent(tx *gorm.DB, event *ContractEvent) (bool, error) {
+	data, err := json.Marshal(event.Args)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode event arguments: %w", err)
+	}
+
+	record := models.ChainEvent{
+		Contract:    event.Contract.Hex(),
+		Name:        event.Name,
+		Data:        string(data),
+		BlockNumber: event.BlockNumber,
+		BlockHash:   event.BlockHash.Hex(),
+		TxHash:      event.TxHash.Hex(),
+		LogIndex:    event.LogIndex,
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to store chain event: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// storeUndecodedLog records a log that could not be decoded, with the raw log
+// in place of the event arguments, and reports whether it was new
+func storeUndecodedLog(tx *gorm.DB, vLog types.Log, decodeErr error) (bool, error) {
+	data, err := json.Marshal(vLog)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode log: %w", err)
+	}
+
+	record := models.ChainEvent{
+		Contract:    vLog.Address.Hex(),
+		Name:        undecodedEventName,
+		Data:        string(data),
+		DecodeError: decodeErr.Error(),
+		BlockNumber: vLog.BlockNumber,
+		BlockHash:   vLog.BlockHash.Hex(),
+		TxHash:      vLog.TxHash.Hex(),
+		LogIndex:    vLog.Index,
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to store undecoded log: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// logChainActivity writes an activity log entry for an on-chain action,
+// linked to the user when the address has one
+func logChainActivity(tx *gorm.DB, address, action string, event *ContractEvent, details map[string]string) error {
+	var userIDs []uint
+	if err := tx.Model(&models.User{}).Where("address = ?", address).Limit(1).Pluck("id", &userIDs).Error; err != nil {
+		return fmt.Errorf("failed to load user: %w", err)
+	}
+
+	activity := models.ActivityLog{
+		Address: address,
+		Action:  action,
+		TxHash:  event.TxHash.Hex(),
+	}
+	if len(userIDs) > 0 {
+		activity.UserID = userIDs[0]
+	}
+	if len(details) > 0 {
+		encoded, err := json.Marshal(details)
+		if err != nil {
+			return fmt.Errorf("failed to encode activity details: %w", err)
+		}
+		activity.Details = string(encoded)
+	}
+	if err := tx.Create(&activity).Error; err != nil {
+		return fmt.Errorf("failed to write activity log: %w", err)
+	}
+	return nil
+}
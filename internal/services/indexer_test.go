@@ -0,0 +1,87 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestIndexerErrorClassification(t *testing.T) {
+	tests := []struct {
+		name            string
+		err             error
+		wantRangeTooBig bool
+		wantRateLimited bool
+	}{
+		{
+			name:            "Infura result limit",
+			err:             errors.New("query returned more than 10000 results"),
+			wantRangeTooBig: true,
+		},
+		{
+			name:            "Alchemy response size",
+			err:             errors.New("Log response size exceeded. You can make eth_getLogs requests with up to a 2K block range and no limit on the response size, or you can request any block range with a cap of 10K logs in the response."),
+			wantRangeTooBig: true,
+		},
+		{
+			name:            "Ankr block range",
+			err:             errors.New("block range is too wide"),
+			wantRangeTooBig: true,
+		},
+		{
+			name:            "BSC maximum block range",
+			err:             errors.New("exceed maximum block range: 5000"),
+			wantRangeTooBig: true,
+		},
+		{
+			name:            "wrapped result limit",
+			err:             fmt.Errorf("failed to fetch logs: %w", errors.New("Query Returned More Than 10000 results")),
+			wantRangeTooBig: true,
+		},
+		{
+			name:            "Alchemy compute units",
+			err:             errors.New("Your app has exceeded its compute units per second capacity. If you have retries enabled, you can safely ignore this message."),
+			wantRateLimited: true,
+		},
+		{
+			name:            "Infura request rate",
+			err:             errors.New("daily request count exceeded, request rate limited"),
+			wantRateLimited: true,
+		},
+		{
+			name:            "HTTP 429",
+			err:             rpc.HTTPError{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"},
+			wantRateLimited: true,
+		},
+		{
+			name:            "rate limit mentioning the block range",
+			err:             errors.New("rate limit exceeded for block range queries"),
+			wantRateLimited: true,
+		},
+		{
+			name: "HTTP 500",
+			err:  rpc.HTTPError{StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error"},
+		},
+		{
+			name: "unknown block",
+			err:  errors.New("header not found"),
+		},
+		{
+			name: "connection refused",
+			err:  errors.New("dial tcp 127.0.0.1:8545: connect: connection refused"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRangeTooLarge(tt.err); got != tt.wantRangeTooBig {
+				t.Errorf("isRangeTooLarge(%q) = %v, want %v", tt.err, got, tt.wantRangeTooBig)
+			}
+			if got := isRateLimited(tt.err); got != tt.wantRateLimited {
+				t.Errorf("isRateLimited(%q) = %v, want %v", tt.err, got, tt.wantRateLimited)
+			}
+		})
+	}
+}
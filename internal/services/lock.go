@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrLockHeld is returned when another instance holds a distributed lock
+var ErrLockHeld = errors.New("operation already in progress")
+
+// releaseLockScript deletes the lock only if it still holds our token, so an
+// instance whose lock expired cannot release a lock taken over by another
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// acquireLock takes a Redis lock for ttl and returns a function that releases
+// it. ErrLockHeld is returned when the lock is taken.
+func acquireLock(ctx context.Context, client *redis.Client, key string, ttl time.Duration) (func(), error) {
+	token, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+
+	acquired, err := client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lock %s: %w", key, err)
+	}
+	if !acquired {
+		return nil, ErrLockHeld
+	}
+
+	release := func() {
+		// Use a fresh context so the lock is released even if ctx was cancelled
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		releaseLockScript.Run(releaseCtx, client, []string{key}, token)
+	}
+	return release, nil
+}
+
+// waitForLock retries acquireLock until the lock is free or wait has passed,
+// for callers that have to run rather than skip when the lock is taken
+func waitForLock(ctx context.Context, client *redis.Client, key string, ttl, wait time.Duration) (func(), error) {
+	deadline := time.Now().Add(wait)
+	for {
+		release, err := acquireLock(ctx, client, key, ttl)
+		if !errors.Is(err, ErrLockHeld) || time.Now().After(deadline) {
+			return release, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"whitelist-token-backend/internal/models"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gorm.io/gorm"
+)
+
+// Merkle errors returned to handlers
+var (
+	ErrNoMerkleRoot    = errors.New("no merkle root has been generated")
+	ErrNotInMerkleTree = errors.New("address is not in the merkle tree")
+	ErrEmptyWhitelist  = errors.New("whitelist is empty")
+)
+
+// Whitelist modes. In mapping mode every change is written to the token
+// contract's whitelist mapping; in merkle mode the whitelist lives off-chain
+// and only the merkle root needs to be published.
+const (
+	WhitelistModeMapping = "mapping"
+	WhitelistModeMerkle  = "merkle"
+)
+
+// merkleLeafArgs is the abi.encode(address, uint256) layout of a leaf
+var merkleLeafArgs = func() abi.Arguments {
+	addressType, _ := abi.NewType("address", "", nil)
+	uint256Type, _ := abi.NewType("uint256", "", nil)
+	return abi.Arguments{{Type: addressType}, {Type: uint256Type}}
+}()
+
+// MerkleProof is the proof served to the frontend for a claim or purchase
+type MerkleProof struct {
+	Address    string   `json:"address"`
+	Allocation string   `json:"allocation"`
+	Leaf       string   `json:"leaf"`
+	Proof      []string `json:"proof"`
+	Root       string   `json:"root"`
+	Version    uint     `json:"version"`
+}
+
+// MerkleLeafHash returns keccak256(keccak256(abi.encode(address, allocation))).
+// Hashing twice matches OpenZeppelin's StandardMerkleTree and prevents a
+// 64-byte inner node from being passed off as a leaf.
+func MerkleLeafHash(address common.Address, allocation *big.Int) (common.Hash, error) {
+	encoded, err := merkleLeafArgs.Pack(address, allocation)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to encode merkle leaf: %w", err)
+	}
+	return crypto.Keccak256Hash(crypto.Keccak256(encoded)), nil
+}
+
+// MerkleTree is a sorted-pair keccak256 tree laid out like OpenZeppelin's
+// StandardMerkleTree, so its roots and proofs match the ones the JavaScript
+// library produces and MerkleProof.verify accepts
+type MerkleTree struct {
+	nodes []common.Hash // Complete binary tree in array form: root first, leaves last in reverse order
+	index map[common.Hash]int
+}
+
+// NewMerkleTree builds a tree from leaf hashes. Leaves are sorted so the root
+// does not depend on the order entries were loaded in.
+func NewMerkleTree(leaves []common.Hash) (*MerkleTree, error) {
+	if len(leaves) == 0 {
+		return nil, ErrEmptyWhitelist
+	}
+
+	sorted := make([]common.Hash, len(leaves))
+	copy(sorted, leaves)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i][:], sorted[j][:]) < 0 })
+
+	tree := &MerkleTree{
+		nodes: make([]common.Hash, 2*len(sorted)-1),
+		index: make(map[common.Hash]int, len(sorted)),
+	}
+	for i, leaf := range sorted {
+		pos := len(tree.nodes) - 1 - i
+		tree.nodes[pos] = leaf
+		tree.index[leaf] = pos
+	}
+	for i := len(tree.nodes) - 1 - len(sorted); i >= 0; i-- {
+		tree.nodes[i] = hashPair(tree.nodes[2*i+1], tree.nodes[2*i+2])
+	}
+
+	return tree, nil
+}
+
+// Root returns the merkle root
+func (t *MerkleTree) Root() common.Hash {
+	return t.nodes[0]
+}
+
+// Proof returns the sibling hashes from leaf to root
+func (t *MerkleTree) Proof(leaf common.Hash) ([]common.Hash, bool) {
+	idx, ok := t.index[leaf]
+	if !ok {
+		return nil, false
+	}
+
+	proof := []common.Hash{}
+	for ; idx > 0; idx = (idx - 1) / 2 {
+		sibling := idx - 1
+		if idx%2 == 1 {
+			sibling = idx + 1
+		}
+		proof = append(proof, t.nodes[sibling])
+	}
+	return proof, true
+}
+
+// VerifyMerkleProof checks a proof the same way OpenZeppelin's MerkleProof.verify does
+func VerifyMerkleProof(proof []common.Hash, root, leaf common.Hash) bool {
+	computed := leaf
+	for _, sibling := range proof {
+		computed = hashPair(computed, sibling)
+	}
+	return computed == root
+}
+
+func hashPair(a, b common.Hash) common.Hash {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+	}
+	return crypto.Keccak256Hash(a[:], b[:])
+}
+
+// GenerateMerkleRoot builds a tree from every whitelisted entry and stores it
+// as the new active version together with each address's proof
+func (ws *WhitelistService) GenerateMerkleRoot(ctx context.Context, createdBy string) (*models.MerkleRoot, error) {
+	var entries []models.WhitelistEntry
+	if err := ws.db.WithContext(ctx).Where("is_whitelisted = ?", true).Order("id").Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to load whitelist entries: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, ErrEmptyWhitelist
+	}
+
+	limits, err := ws.allocationLimits(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	leaves := make([]models.MerkleLeaf, len(entries))
+	hashes := make([]common.Hash, len(entries))
+	for i, entry := range entries {
+		allocation := limits.effective(entry)
+		hash, err := MerkleLeafHash(common.HexToAddress(entry.Address), allocation)
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = hash
+		leaves[i] = models.MerkleLeaf{
+			Address:    entry.Address,
+			Allocation: allocation.String(),
+			LeafHash:   hash.Hex(),
+		}
+	}
+
+	tree, err := NewMerkleTree(hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range leaves {
+		proof, _ := tree.Proof(hashes[i])
+		encoded, err := json.Marshal(hashesToHex(proof))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode merkle proof: %w", err)
+		}
+		leaves[i].Proof = string(encoded)
+	}
+
+	root := models.MerkleRoot{
+		Root:      tree.Root().Hex(),
+		LeafCount: len(leaves),
+		IsActive:  true,
+		CreatedBy: createdBy,
+	}
+	err = ws.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var latest uint
+		if err := tx.Model(&models.MerkleRoot{}).Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return fmt.Errorf("failed to load latest merkle version: %w", err)
+		}
+		root.Version = latest + 1
+
+		if err := tx.Model(&models.MerkleRoot{}).Where("is_active = ?", true).Update("is_active", false).Error; err != nil {
+			return fmt.Errorf("failed to deactivate merkle roots: %w", err)
+		}
+		if err := tx.Create(&root).Error; err != nil {
+			return fmt.Errorf("failed to store merkle root: %w", err)
+		}
+
+		for i := range leaves {
+			leaves[i].RootID = root.ID
+		}
+		if err := tx.CreateInBatches(leaves, 1000).Error; err != nil {
+			return fmt.Errorf("failed to store merkle leaves: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ws.logger.WithField("version", root.Version).WithField("root", root.Root).WithField("leaves", root.LeafCount).Info("Merkle root generated")
+	return &root, nil
+}
+
+// ListMerkleRoots returns all merkle root versions, newest first
+func (ws *WhitelistService) ListMerkleRoots(ctx context.Context) ([]models.MerkleRoot, error) {
+	var roots []models.MerkleRoot
+	if err := ws.db.WithContext(ctx).Order("version DESC").Find(&roots).Error; err != nil {
+		return nil, fmt.Errorf("failed to list merkle roots: %w", err)
+	}
+	return roots, nil
+}
+
+// GetMerkleProof returns the proof for an address in the active merkle root
+func (ws *WhitelistService) GetMerkleProof(ctx context.Context, address string) (*MerkleProof, error) {
+	address, err := NormalizeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := ws.activeMerkleRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var leaf models.MerkleLeaf
+	if err := ws.db.WithContext(ctx).Where("root_id = ? AND address = ?", root.ID, address).First(&leaf).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotInMerkleTree
+		}
+		return nil, fmt.Errorf("failed to load merkle leaf: %w", err)
+	}
+
+	var proof []string
+	if err := json.Unmarshal([]byte(leaf.Proof), &proof); err != nil {
+		return nil, fmt.Errorf("failed to decode merkle proof: %w", err)
+	}
+
+	return &MerkleProof{
+		Address:    address,
+		Allocation: leaf.Allocation,
+		Leaf:       leaf.LeafHash,
+		Proof:      proof,
+		Root:       root.Root,
+		Version:    root.Version,
+	}, nil
+}
+
+// VerifyMerkleProof checks a client-supplied proof for address and allocation
+// against the active merkle root
+func (ws *WhitelistService) VerifyMerkleProof(ctx context.Context, address, allocation string, proof []string) (bool, *models.MerkleRoot, error) {
+	address, err := NormalizeAddress(address)
+	if err != nil {
+		return false, nil, err
+	}
+	amount, ok := new(big.Int).SetString(allocation, 10)
+	if !ok || amount.Sign() < 0 {
+		return false, nil, fmt.Errorf("%w: %q", ErrInvalidAllocation, allocation)
+	}
+
+	root, err := ws.activeMerkleRoot(ctx)
+	if err != nil {
+		return false, nil, err
+	}
+
+	siblings := make([]common.Hash, 0, len(proof))
+	for _, p := range proof {
+		p = strings.TrimSpace(p)
+		if len(p) != 66 || !strings.HasPrefix(p, "0x") {
+			return false, root, nil
+		}
+		siblings = append(siblings, common.HexToHash(p))
+	}
+
+	leaf, err := MerkleLeafHash(common.HexToAddress(address), amount)
+	if err != nil {
+		return false, nil, err
+	}
+
+	return VerifyMerkleProof(siblings, common.HexToHash(root.Root), leaf), root, nil
+}
+
+func (ws *WhitelistService) activeMerkleRoot(ctx context.Context) (*models.MerkleRoot, error) {
+	var root models.MerkleRoot
+	if err := ws.db.WithContext(ctx).Where("is_active = ?", true).First(&root).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoMerkleRoot
+		}
+		return nil, fmt.Errorf("failed to load merkle root: %w", err)
+	}
+	return &root, nil
+}
+
+func hashesToHex(hashes []common.Hash) []string {
+	out := make([]string, len(hashes))
+	for i, h := range hashes {
+		out[i] = h.Hex()
+	}
+	return out
+}
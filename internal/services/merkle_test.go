@@ -0,0 +1,113 @@
+package services
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func mustLeaf(t *testing.T, address string, allocation string) common.Hash {
+	t.Helper()
+	amount, ok := new(big.Int).SetString(allocation, 10)
+	if !ok {
+		t.Fatalf("bad allocation %q", allocation)
+	}
+	leaf, err := MerkleLeafHash(common.HexToAddress(address), amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf
+}
+
+func sortedLeaves(leaves []common.Hash) []common.Hash {
+	sorted := append([]common.Hash(nil), leaves...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i][:], sorted[j][:]) < 0 })
+	return sorted
+}
+
+func TestMerkleTreeMatchesStandardMerkleTree(t *testing.T) {
+	// The example from the @openzeppelin/merkle-tree README:
+	// StandardMerkleTree.of(values, ["address", "uint256"])
+	a := mustLeaf(t, "0x1111111111111111111111111111111111111111", "5000000000000000000")
+	b := mustLeaf(t, "0x2222222222222222222222222222222222222222", "2500000000000000000")
+	c := mustLeaf(t, "0x3333333333333333333333333333333333333333", "1000000000000000000")
+	d := mustLeaf(t, "0x4444444444444444444444444444444444444444", "1")
+	e := mustLeaf(t, "0x5555555555555555555555555555555555555555", "0")
+
+	tests := []struct {
+		name   string
+		leaves []common.Hash
+		// root in StandardMerkleTree's array layout, where the sorted leaves
+		// h[0..n-1] sit at the end of the array in reverse order
+		root func(h []common.Hash) common.Hash
+	}{
+		{
+			name:   "readme example",
+			leaves: []common.Hash{a, b},
+			root: func([]common.Hash) common.Hash {
+				return common.HexToHash("0xd4dee0beab2d53f2cc83e567171bd2820e49898130a22622b10ead383e90bd77")
+			},
+		},
+		{
+			name:   "single leaf",
+			leaves: []common.Hash{c},
+			root:   func(h []common.Hash) common.Hash { return h[0] },
+		},
+		{
+			name:   "three leaves",
+			leaves: []common.Hash{a, b, c},
+			root:   func(h []common.Hash) common.Hash { return hashPair(hashPair(h[1], h[0]), h[2]) },
+		},
+		{
+			name:   "five leaves",
+			leaves: []common.Hash{a, b, c, d, e},
+			root: func(h []common.Hash) common.Hash {
+				return hashPair(hashPair(hashPair(h[1], h[0]), h[4]), hashPair(h[3], h[2]))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := NewMerkleTree(tt.leaves)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.root(sortedLeaves(tt.leaves))
+			if tree.Root() != want {
+				t.Fatalf("root = %s, want %s", tree.Root().Hex(), want.Hex())
+			}
+			for _, leaf := range tt.leaves {
+				proof, ok := tree.Proof(leaf)
+				if !ok {
+					t.Fatalf("no proof for %s", leaf.Hex())
+				}
+				if !VerifyMerkleProof(proof, want, leaf) {
+					t.Fatalf("proof for %s does not verify", leaf.Hex())
+				}
+			}
+		})
+	}
+}
+
+func TestMerkleProofMatchesStandardMerkleTree(t *testing.T) {
+	a := mustLeaf(t, "0x1111111111111111111111111111111111111111", "5000000000000000000")
+	b := mustLeaf(t, "0x2222222222222222222222222222222222222222", "2500000000000000000")
+	tree, err := NewMerkleTree([]common.Hash{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// tree.getProof(0) in the @openzeppelin/merkle-tree README example
+	proof, _ := tree.Proof(a)
+	want := common.HexToHash("0xb92c48e9d7abe27fd8dfd6b5dfdbfb1c9a463f80c712b66f3a5180a090cccafc")
+	if len(proof) != 1 || proof[0] != want {
+		t.Fatalf("proof = %v, want [%s]", hashesToHex(proof), want.Hex())
+	}
+
+	if VerifyMerkleProof(proof, tree.Root(), mustLeaf(t, "0x1111111111111111111111111111111111111111", "5000000000000000001")) {
+		t.Fatal("proof verified for a different allocation")
+	}
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"whitelist-token-backend/internal/models"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RBAC errors returned to handlers
+var (
+	ErrRoleNotFound   = errors.New("role not found")
+	ErrUserNotFound   = errors.New("user not found")
+	ErrLastSuperAdmin = errors.New("cannot revoke the last super admin")
+	ErrRoleNotHeld    = errors.New("user does not hold the role")
+)
+
+// permissionCacheTTL bounds how long a revoked permission can still be honoured
+const permissionCacheTTL = time.Minute
+
+// roleRank orders the built-in roles so a user's primary role can be derived
+var roleRank = map[string]int{
+	models.RoleUser:              0,
+	models.RoleWhitelistOperator: 1,
+	models.RoleAdmin:             2,
+	models.RoleSuperAdmin:        3,
+}
+
+// RBACService handles role and permission lookups backed by Postgres
+type RBACService struct {
+	db     *gorm.DB
+	redis  *redis.Client
+	logger *logrus.Logger
+}
+
+// NewRBACService creates a new RBAC service
+func NewRBACService(db *gorm.DB, redis *redis.Client, logger *logrus.Logger) *RBACService {
+	return &RBACService{
+		db:     db,
+		redis:  redis,
+		logger: logger,
+	}
+}
+
+// EnsureSuperAdmins grants super_admin to the configured bootstrap addresses
+func (rs *RBACService) EnsureSuperAdmins(ctx context.Context, addresses []string) error {
+	for _, address := range addresses {
+		checksummed, err := NormalizeAddress(address)
+		if err != nil {
+			return fmt.Errorf("invalid admin address %q: %w", address, err)
+		}
+		if err := rs.GrantRole(ctx, checksummed, models.RoleSuperAdmin, "bootstrap"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListRoles returns all roles with their permissions
+func (rs *RBACService) ListRoles(ctx context.Context) ([]models.Role, error) {
+	var roles []models.Role
+	if err := rs.db.WithContext(ctx).Preload("Permissions").Order("id").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	return roles, nil
+}
+
+// UserRoles returns the names of the roles granted to an address
+func (rs *RBACService) UserRoles(ctx context.Context, address string) ([]string, error) {
+	var names []string
+	err := rs.db.WithContext(ctx).
+		Table("user_roles").
+		Select("roles.name").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Joins("JOIN users ON users.id = user_roles.user_id").
+		Where("users.address = ? AND users.deleted_at IS NULL", address).
+		Order("roles.name").
+		Pluck("roles.name", &names).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user roles: %w", err)
+	}
+	return names, nil
+}
+
+// PrimaryRole returns the most privileged built-in role held by an address
+func (rs *RBACService) PrimaryRole(ctx context.Context, address string) (string, error) {
+	roles, err := rs.UserRoles(ctx, address)
+	if err != nil {
+		return "", err
+	}
+
+	primary := models.RoleUser
+	for _, role := range roles {
+		if roleRank[role] > roleRank[primary] {
+			primary = role
+		}
+	}
+	return primary, nil
+}
+
+// Permissions returns the permission names an address holds through its roles
+func (rs *RBACService) Permissions(ctx context.Context, address string) ([]string, error) {
+	cacheKey := permissionCacheKey(address)
+	if cached, err := rs.redis.Get(ctx, cacheKey).Bytes(); err == nil {
+		var permissions []string
+		if err := json.Unmarshal(cached, &permissions); err == nil {
+			return permissions, nil
+		}
+	} else if !errors.Is(err, redis.Nil) {
+		rs.logger.WithError(err).Warn("Failed to read permission cache")
+	}
+
+	var permissions []string
+	err := rs.db.WithContext(ctx).
+		Table("user_roles").
+		Distinct("permissions.name").
+		Joins("JOIN users ON users.id = user_roles.user_id").
+		Joins("JOIN role_permissions ON role_permissions.role_id = user_roles.role_id").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("users.address = ? AND users.is_active = ? AND users.deleted_at IS NULL", address, true).
+		Pluck("permissions.name", &permissions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load permissions: %w", err)
+	}
+
+	if encoded, err := json.Marshal(permissions); err == nil {
+		if err := rs.redis.Set(ctx, cacheKey, encoded, permissionCacheTTL).Err(); err != nil {
+			rs.logger.WithError(err).Warn("Failed to write permission cache")
+		}
+	}
+
+	return permissions, nil
+}
+
+// HasPermission reports whether an address holds a permission
+func (rs *RBACService) HasPermission(ctx context.Context, address, permission string) (bool, error) {
+	permissions, err := rs.Permissions(ctx, address)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range permissions {
+		if p == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GrantRole grants a role to an address, creating the user if needed
+func (rs *RBACService) GrantRole(ctx context.Context, address, roleName, grantedBy string) error {
+	err := rs.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+		if err := tx.Where("name = ?", roleName).First(&role).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRoleNotFound
+			}
+			return fmt.Errorf("failed to load role: %w", err)
+		}
+
+		user, err := findOrCreateUser(tx, address)
+		if err != nil {
+			return err
+		}
+
+		userRole := models.UserRole{UserID: user.ID, RoleID: role.ID, GrantedBy: grantedBy}
+		if err := tx.Where(models.UserRole{UserID: user.ID, RoleID: role.ID}).FirstOrCreate(&userRole).Error; err != nil {
+			return fmt.Errorf("failed to grant role: %w", err)
+		}
+
+		return syncIsAdmin(tx, user.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	rs.invalidate(ctx, address)
+	rs.logger.WithFields(logrus.Fields{
+		"address":    address,
+		"role":       roleName,
+		"granted_by": grantedBy,
+	}).Info("Role granted")
+	return nil
+}
+
+// RevokeRole removes a role from an address
+func (rs *RBACService) RevokeRole(ctx context.Context, address, roleName string) error {
+	err := rs.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Locking the role serialises revokes of it, so two admins revoking
+		// each other cannot both see the other as the remaining super admin
+		var role models.Role
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("name = ?", roleName).First(&role).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRoleNotFound
+			}
+			return fmt.Errorf("failed to load role: %w", err)
+		}
+
+		var user models.User
+		if err := tx.Where("address = ?", address).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("failed to load user: %w", err)
+		}
+
+		result := tx.Where("user_id = ? AND role_id = ?", user.ID, role.ID).Delete(&models.UserRole{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to revoke role: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrRoleNotHeld
+		}
+
+		// Rolling back keeps at least one account able to manage roles
+		if roleName == models.RoleSuperAdmin {
+			var remaining int64
+			if err := tx.Model(&models.UserRole{}).Where("role_id = ?", role.ID).Count(&remaining).Error; err != nil {
+				return fmt.Errorf("failed to count super admins: %w", err)
+			}
+			if remaining == 0 {
+				return ErrLastSuperAdmin
+			}
+		}
+
+		return syncIsAdmin(tx, user.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	rs.invalidate(ctx, address)
+	rs.logger.WithFields(logrus.Fields{
+		"address": address,
+		"role":    roleName,
+	}).Info("Role revoked")
+	return nil
+}
+
+func (rs *RBACService) invalidate(ctx context.Context, address string) {
+	if err := rs.redis.Del(ctx, permissionCacheKey(address)).Err(); err != nil {
+		rs.logger.WithError(err).WithField("address", address).Warn("Failed to invalidate permission cache")
+	}
+}
+
+// syncIsAdmin keeps the legacy User.IsAdmin flag in line with the user's roles
+func syncIsAdmin(tx *gorm.DB, userID uint) error {
+	var privileged int64
+	err := tx.Model(&models.UserRole{}).
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where("user_roles.user_id = ? AND roles.name <> ?", userID, models.RoleUser).
+		Count(&privileged).Error
+	if err != nil {
+		return fmt.Errorf("failed to count roles: %w", err)
+	}
+
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("is_admin", privileged > 0).Error; err != nil {
+		return fmt.Errorf("failed to update admin flag: %w", err)
+	}
+	return nil
+}
+
+func permissionCacheKey(address string) string { return "rbac:permissions:" + address }
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"whitelist-token-backend/internal/models"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	reconcileLockKey = "lock:whitelist:reconcile"
+	reconcileLockTTL = 10 * time.Minute
+	// repairChunkSize keeps a single updateWhitelistBatch transaction well under the block gas limit
+	repairChunkSize = 100
+)
+
+// ReconcilerConfig controls the periodic whitelist reconciliation
+type ReconcilerConfig struct {
+	Interval   time.Duration // Zero disables the background loop
+	BatchSize  int
+	AutoRepair bool
+}
+
+// ReconcileReport summarises a reconciliation run
+type ReconcileReport struct {
+	Checked    int       `json:"checked"`
+	InSync     int       `json:"in_sync"`
+	Drifted    int       `json:"drifted"`
+	Repaired   int       `json:"repaired"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// ReconcilerService compares whitelist entries with the token contract's
+// whitelist mapping and records any drift between the two. Only addresses
+// known to the database are checked.
+type ReconcilerService struct {
+	db                *gorm.DB
+	redis             *redis.Client
+	blockchainService *BlockchainService
+	config            ReconcilerConfig
+	logger            *logrus.Logger
+}
+
+// NewReconcilerService creates a new reconciler service
+func NewReconcilerService(
+	db *gorm.DB,
+	redis *redis.Client,
+	blockchainService *BlockchainService,
+	config ReconcilerConfig,
+	logger *logrus.Logger,
+) *ReconcilerService {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 200
+	}
+	return &ReconcilerService{
+		db:                db,
+		redis:             redis,
+		blockchainService: blockchainService,
+		config:            config,
+		logger:            logger,
+	}
+}
+
+// Start runs reconciliation every configured interval until ctx is cancelled
+func (rs *ReconcilerService) Start(ctx context.Context) {
+	if rs.config.Interval <= 0 {
+		rs.logger.Info("Whitelist reconciler disabled")
+		return
+	}
+
+	ticker := time.NewTicker(rs.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := rs.RunOnce(ctx); err != nil && !errors.Is(err, ErrLockHeld) && ctx.Err() == nil {
+			rs.logger.WithError(err).Error("Whitelist reconciliation failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce performs a single reconciliation pass. Only one instance runs at a
+// time; ErrLockHeld is returned if another pass is in progress.
+func (rs *ReconcilerService) RunOnce(ctx context.Context) (*ReconcileReport, error) {
+	release, err := acquireLock(ctx, rs.redis, reconcileLockKey, reconcileLockTTL)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	report := &ReconcileReport{StartedAt: time.Now()}
+	var drifted []models.WhitelistDrift
+
+	// Pending entries have a transaction in flight and are skipped until it settles
+	var entries []models.WhitelistEntry
+	err = rs.db.WithContext(ctx).
+		Where("status <> ?", models.WhitelistStatusPending).
+		Order("id").
+		FindInBatches(&entries, rs.config.BatchSize, func(tx *gorm.DB, batch int) error {
+			found, err := rs.checkBatch(ctx, entries, report)
+			if err != nil {
+				return err
+			}
+			drifted = append(drifted, found...)
+			return nil
+		}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile whitelist: %w", err)
+	}
+
+	if rs.config.AutoRepair && len(drifted) > 0 {
+		report.Repaired = rs.repair(ctx, drifted)
+	}
+
+	report.FinishedAt = time.Now()
+	rs.logger.WithFields(logrus.Fields{
+		"checked":  report.Checked,
+		"in_sync":  report.InSync,
+		"drifted":  report.Drifted,
+		"repaired": report.Repaired,
+	}).Info("Whitelist reconciliation completed")
+	return report, nil
+}
+
+// ListDrift returns drift records, newest first. Resolved records are only
+// included when includeResolved is set.
+func (rs *ReconcilerService) ListDrift(ctx context.Context, includeResolved bool) ([]models.WhitelistDrift, error) {
+	query := rs.db.WithContext(ctx).Order("detected_at DESC")
+	if !includeResolved {
+		query = query.Where("resolved_at IS NULL")
+	}
+
+	var drifts []models.WhitelistDrift
+	if err := query.Find(&drifts).Error; err != nil {
+		return nil, fmt.Errorf("failed to list whitelist drift: %w", err)
+	}
+	return drifts, nil
+}
+
+// checkBatch compares one batch of entries with the chain and returns the open drift records for mismatches
+func (rs *ReconcilerService) checkBatch(ctx context.Context, entries []models.WhitelistEntry, report *ReconcileReport) ([]models.WhitelistDrift, error) {
+	addresses := make([]string, len(entries))
+	for i, entry := range entries {
+		addresses[i] = entry.Address
+	}
+
+	onChain, err := rs.blockchainService.IsWhitelistedBatch(ctx, addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var inSync []string
+	var drifted []models.WhitelistDrift
+
+	for _, entry := range entries {
+		report.Checked++
+		chainWhitelisted := onChain[entry.Address]
+		if chainWhitelisted == entry.IsWhitelisted {
+			report.InSync++
+			inSync = append(inSync, entry.Address)
+			continue
+		}
+
+		report.Drifted++
+		drift, err := rs.recordDrift(ctx, entry, chainWhitelisted, now)
+		if err != nil {
+			return nil, err
+		}
+		drifted = append(drifted, *drift)
+	}
+
+	if len(inSync) > 0 {
+		err := rs.db.WithContext(ctx).Model(&models.WhitelistDrift{}).
+			Where("address IN ? AND resolved_at IS NULL", inSync).
+			Updates(map[string]interface{}{"resolved_at": now, "resolution": models.DriftResolutionInSync}).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve whitelist drift: %w", err)
+		}
+	}
+
+	return drifted, nil
+}
+
+// recordDrift updates the open drift record for an address or opens a new one
+func (rs *ReconcilerService) recordDrift(ctx context.Context, entry models.WhitelistEntry, chainWhitelisted bool, now time.Time) (*models.WhitelistDrift, error) {
+	var drift models.WhitelistDrift
+	err := rs.db.WithContext(ctx).Where("address = ? AND resolved_at IS NULL", entry.Address).First(&drift).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to load whitelist drift: %w", err)
+	}
+
+	if drift.ID == 0 {
+		drift.Address = entry.Address
+		drift.DetectedAt = now
+		rs.logger.WithFields(logrus.Fields{
+			"address":           entry.Address,
+			"db_whitelisted":    entry.IsWhitelisted,
+			"chain_whitelisted": chainWhitelisted,
+		}).Warn("Whitelist drift detected")
+	}
+	drift.DBWhitelisted = entry.IsWhitelisted
+	drift.ChainWhitelisted = chainWhitelisted
+	drift.EntryStatus = entry.Status
+	drift.LastSeenAt = now
+
+	if err := rs.db.WithContext(ctx).Save(&drift).Error; err != nil {
+		return nil, fmt.Errorf("failed to save whitelist drift: %w", err)
+	}
+	return &drift, nil
+}
+
+// repair pushes the database state to the chain with updateWhitelistBatch
+// transactions and returns how many addresses were repaired
+func (rs *ReconcilerService) repair(ctx context.Context, drifts []models.WhitelistDrift) int {
+	var toAdd, toRemove []string
+	for _, drift := range drifts {
+		if drift.DBWhitelisted {
+			toAdd = append(toAdd, drift.Address)
+		} else {
+			toRemove = append(toRemove, drift.Address)
+		}
+	}
+
+	repaired := 0
+	for _, op := range []struct {
+		addresses []string
+		add       bool
+	}{{toAdd, true}, {toRemove, false}} {
+		for start := 0; start < len(op.addresses); start += repairChunkSize {
+			end := min(start+repairChunkSize, len(op.addresses))
+			chunk := op.addresses[start:end]
+
+			var record *models.Transaction
+			var err error
+			if op.add {
+				record, err = rs.blockchainService.AddToWhitelist(ctx, chunk)
+			} else {
+				record, err = rs.blockchainService.RemoveFromWhitelist(ctx, chunk)
+			}
+			if err == nil {
+				_, err = rs.blockchainService.WaitMined(ctx, record)
+			}
+			var txHash string
+			if record != nil {
+				txHash = record.TxHash
+			}
+			if err != nil {
+				rs.logger.WithError(err).WithFields(logrus.Fields{
+					"addresses": len(chunk),
+					"whitelist": op.add,
+					"tx_hash":   txHash,
+				}).Error("Failed to repair whitelist drift")
+				continue
+			}
+
+			if err := rs.markRepaired(ctx, chunk, txHash); err != nil {
+				rs.logger.WithError(err).WithField("tx_hash", txHash).Error("Failed to record whitelist repair")
+				continue
+			}
+			repaired += len(chunk)
+		}
+	}
+
+	return repaired
+}
+
+func (rs *ReconcilerService) markRepaired(ctx context.Context, addresses []string, txHash string) error {
+	now := time.Now()
+	return rs.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.WhitelistDrift{}).
+			Where("address IN ? AND resolved_at IS NULL", addresses).
+			Updates(map[string]interface{}{
+				"resolved_at":    now,
+				"resolution":     models.DriftResolutionRepaired,
+				"repair_tx_hash": txHash,
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.WhitelistEntry{}).
+			Where("address IN ?", addresses).
+			Updates(map[string]interface{}{
+				"status":  models.WhitelistStatusConfirmed,
+				"tx_hash": txHash,
+			}).Error
+	})
+}
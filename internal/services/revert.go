@@ -0,0 +1,138 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"whitelist-token-backend/internal/models"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Selectors of the errors Solidity raises itself: require/revert messages
+// and failed assertions, overflows and the like
+var (
+	errorStringSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+	panicSelector       = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+)
+
+// ReplayRevert replays a reverted transaction with eth_call at the block it
+// was mined in and returns the decoded revert reason. The reason is empty when
+// the contract reverted without data or the replay no longer reverts.
+func (bs *BlockchainService) ReplayRevert(ctx context.Context, record *models.Transaction, blockNumber *big.Int) (string, error) {
+	signed, err := decodeRawTx(record.RawTx)
+	if err != nil {
+		return "", err
+	}
+
+	// Fees are left out, so the replay only depends on the contract state
+	msg := ethereum.CallMsg{
+		From:  common.HexToAddress(record.FromAddress),
+		To:    signed.To(),
+		Gas:   signed.Gas(),
+		Value: signed.Value(),
+		Data:  signed.Data(),
+	}
+	_, err = bs.client.CallContract(ctx, msg, blockNumber)
+	switch {
+	case err == nil:
+		return "", nil
+	case strings.Contains(err.Error(), "out of gas"):
+		return "out of gas", nil
+	case !isExecutionReverted(err):
+		return "", fmt.Errorf("failed to replay transaction: %w", err)
+	}
+	return bs.revertReason(err), nil
+}
+
+// revertReason decodes the revert data of a failed call or gas estimate.
+// Nodes that return no data leave the reason in the message, if at all.
+func (bs *BlockchainService) revertReason(err error) string {
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if encoded, ok := dataErr.ErrorData().(string); ok {
+			if data, decodeErr := hexutil.Decode(encoded); decodeErr == nil && len(data) > 0 {
+				return bs.decodeRevert(data)
+			}
+		}
+	}
+	if _, reason, found := strings.Cut(err.Error(), "execution reverted: "); found {
+		return reason
+	}
+	return ""
+}
+
+// callError adds the decoded revert reason to the error of a reverted call,
+// unless the node already put it in the message
+func (bs *BlockchainService) callError(method string, err error) error {
+	if err == nil || !isExecutionReverted(err) {
+		return err
+	}
+	reason := bs.revertReason(err)
+	if reason == "" || strings.Contains(err.Error(), reason) {
+		return err
+	}
+	return fmt.Errorf("%s reverted with %s: %w", method, reason, err)
+}
+
+// decodeRevert decodes Error(string), Panic(uint256) and the custom errors
+// defined in the sale and token ABIs
+func (bs *BlockchainService) decodeRevert(data []byte) string {
+	if len(data) < 4 {
+		return ""
+	}
+
+	selector := data[:4]
+	if bytes.Equal(selector, errorStringSelector) || bytes.Equal(selector, panicSelector) {
+		reason, err := abi.UnpackRevert(data)
+		if err != nil {
+			return fmt.Sprintf("malformed revert data %s", hexutil.Encode(data))
+		}
+		if bytes.Equal(selector, panicSelector) {
+			return "panic: " + reason
+		}
+		return reason
+	}
+
+	for _, contractABI := range []abi.ABI{bs.saleABI, bs.tokenABI} {
+		for _, customErr := range contractABI.Errors {
+			if !bytes.Equal(selector, customErr.ID[:4]) {
+				continue
+			}
+			values, err := customErr.Unpack(data)
+			if err != nil {
+				continue
+			}
+			return formatCustomError(customErr, values.([]interface{}))
+		}
+	}
+	return fmt.Sprintf("unknown error %s", hexutil.Encode(selector))
+}
+
+// formatCustomError renders a decoded custom error as Name(arg: value, ...)
+func formatCustomError(customErr abi.Error, values []interface{}) string {
+	args := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case common.Address:
+			args[i] = v.Hex()
+		case []byte:
+			args[i] = hexutil.Encode(v)
+		case [32]byte:
+			args[i] = hexutil.Encode(v[:])
+		default:
+			args[i] = fmt.Sprint(v)
+		}
+		args[i] = customErr.Inputs[i].Name + ": " + args[i]
+	}
+	return customErr.Name + "(" + strings.Join(args, ", ") + ")"
+}
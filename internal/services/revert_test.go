@@ -0,0 +1,82 @@
+package services
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	testSaleErrorsABI = `[
+		{"type": "error", "name": "ExceedsAllocation", "inputs": [
+			{"name": "buyer", "type": "address"},
+			{"name": "requested", "type": "uint256"},
+			{"name": "remaining", "type": "uint256"}
+		]},
+		{"type": "error", "name": "SaleClosed", "inputs": []}
+	]`
+	testTokenErrorsABI = `[
+		{"type": "error", "name": "InvalidProof", "inputs": [{"name": "root", "type": "bytes32"}]}
+	]`
+)
+
+// revertData encodes a revert with the given selector and arguments
+func revertData(t *testing.T, selector []byte, args abi.Arguments, values ...interface{}) []byte {
+	t.Helper()
+	packed, err := args.Pack(values...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(append([]byte{}, selector...), packed...)
+}
+
+func TestDecodeRevert(t *testing.T) {
+	saleABI, err := abi.JSON(strings.NewReader(testSaleErrorsABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenABI, err := abi.JSON(strings.NewReader(testTokenErrorsABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := &BlockchainService{saleABI: saleABI, tokenABI: tokenABI}
+
+	stringType, _ := abi.NewType("string", "", nil)
+	uintType, _ := abi.NewType("uint256", "", nil)
+	buyer := common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266")
+	root := common.HexToHash("0x01")
+
+	exceeds := saleABI.Errors["ExceedsAllocation"]
+	closed := saleABI.Errors["SaleClosed"]
+	invalidProof := tokenABI.Errors["InvalidProof"]
+	errorString := revertData(t, errorStringSelector, abi.Arguments{{Type: stringType}}, "Not whitelisted")
+	customError := revertData(t, exceeds.ID[:4], exceeds.Inputs, buyer, big.NewInt(500), big.NewInt(100))
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"Error(string)", errorString, "Not whitelisted"},
+		{"Panic(uint256)", revertData(t, panicSelector, abi.Arguments{{Type: uintType}}, big.NewInt(0x11)), "panic: arithmetic underflow or overflow"},
+		{"unknown panic code", revertData(t, panicSelector, abi.Arguments{{Type: uintType}}, big.NewInt(0x99)), "panic: unknown panic code: 0x99"},
+		{"custom error with arguments", customError, "ExceedsAllocation(buyer: " + buyer.Hex() + ", requested: 500, remaining: 100)"},
+		{"custom error without arguments", closed.ID[:4], "SaleClosed()"},
+		{"custom error from the token ABI", revertData(t, invalidProof.ID[:4], invalidProof.Inputs, root), "InvalidProof(root: " + root.Hex() + ")"},
+		{"unknown selector", []byte{0xde, 0xad, 0xbe, 0xef, 0x00}, "unknown error 0xdeadbeef"},
+		{"truncated Error(string)", errorString[:40], "malformed revert data 0x" + common.Bytes2Hex(errorString[:40])},
+		{"truncated custom error", customError[:36], "unknown error 0x" + common.Bytes2Hex(exceeds.ID[:4])},
+		{"shorter than a selector", []byte{0x08, 0xc3}, ""},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bs.decodeRevert(tt.data); got != tt.want {
+				t.Fatalf("decodeRevert(%x) = %q, want %q", tt.data, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"whitelist-token-backend/internal/models"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Schedule errors returned to handlers
+var (
+	ErrInvalidWindow           = errors.New("a window needs valid_from or valid_until, and valid_until must be in the future and after valid_from")
+	ErrScheduledChangeNotFound = errors.New("scheduled change not found")
+)
+
+const (
+	scheduleLockKey = "lock:whitelist:schedule"
+	scheduleLockTTL = 2 * time.Minute
+	// scheduleActor is recorded as the creator of batch jobs submitted by the scheduler
+	scheduleActor = "scheduler"
+	// maxDuePerRun bounds how many due changes go into a single batch job,
+	// along with the batch service's own operation limit
+	maxDuePerRun = 5000
+)
+
+// ScheduleRequest whitelists an address for a time window. A nil ValidFrom
+// starts the window immediately; a nil ValidUntil leaves it open-ended.
+type ScheduleRequest struct {
+	Address       string     `json:"address" binding:"required"`
+	MaxAllocation string     `json:"max_allocation"`
+	Tier          string     `json:"tier"`
+	ValidFrom     *time.Time `json:"valid_from"`
+	ValidUntil    *time.Time `json:"valid_until"`
+}
+
+// SchedulerService submits time-boxed whitelist changes when they fall due.
+// Due changes are queued as batch jobs, so chunking, receipts and retries are
+// handled by the BatchService.
+type SchedulerService struct {
+	db               *gorm.DB
+	redis            *redis.Client
+	whitelistService *WhitelistService
+	batchService     *BatchService
+	interval         time.Duration
+	logger           *logrus.Logger
+}
+
+// NewSchedulerService creates a new scheduler service
+func NewSchedulerService(
+	db *gorm.DB,
+	redis *redis.Client,
+	whitelistService *WhitelistService,
+	batchService *BatchService,
+	interval time.Duration,
+	logger *logrus.Logger,
+) *SchedulerService {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &SchedulerService{
+		db:               db,
+		redis:            redis,
+		whitelistService: whitelistService,
+		batchService:     batchService,
+		interval:         interval,
+		logger:           logger,
+	}
+}
+
+// Schedule stores an address's eligibility window and replaces any changes
+// still scheduled for it with an add at ValidFrom and a removal at ValidUntil
+func (ss *SchedulerService) Schedule(ctx context.Context, req ScheduleRequest, actor string) (*models.WhitelistEntry, []models.ScheduledChange, error) {
+	address, err := NormalizeAddress(req.Address)
+	if err != nil {
+		return nil, nil, err
+	}
+	allocation := ""
+	if req.MaxAllocation != "" {
+		if allocation, err = parseAllocation(req.MaxAllocation); err != nil {
+			return nil, nil, err
+		}
+	}
+	tier := strings.ToLower(strings.TrimSpace(req.Tier))
+	if err := ss.whitelistService.checkTier(ctx, tier); err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if req.ValidFrom == nil && req.ValidUntil == nil {
+		return nil, nil, ErrInvalidWindow
+	}
+	if req.ValidUntil != nil && (!req.ValidUntil.After(now) || (req.ValidFrom != nil && !req.ValidUntil.After(*req.ValidFrom))) {
+		return nil, nil, ErrInvalidWindow
+	}
+
+	var entry models.WhitelistEntry
+	var changes []models.ScheduledChange
+	err = ss.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := findOrCreateUser(tx, address)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Unscoped().Where("address = ?", address).First(&entry).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load whitelist entry: %w", err)
+		}
+
+		if entry.ID == 0 || entry.DeletedAt.Valid {
+			entry.IsWhitelisted = false
+			entry.Status = models.WhitelistStatusScheduled
+			entry.DeletedAt = gorm.DeletedAt{}
+		}
+		entry.UserID = user.ID
+		entry.Address = address
+		if allocation != "" {
+			entry.MaxAllocation = allocation
+		}
+		if entry.MaxAllocation == "" {
+			entry.MaxAllocation = "0"
+		}
+		if entry.UsedAllocation == "" {
+			entry.UsedAllocation = "0"
+		}
+		if tier != "" {
+			entry.Tier = tier
+		}
+		entry.ValidFrom = req.ValidFrom
+		entry.ValidUntil = req.ValidUntil
+		if err := tx.Unscoped().Save(&entry).Error; err != nil {
+			return fmt.Errorf("failed to save whitelist entry: %w", err)
+		}
+
+		err = tx.Model(&models.ScheduledChange{}).
+			Where("address = ? AND status = ?", address, models.ScheduleStatusScheduled).
+			Updates(map[string]interface{}{"status": models.ScheduleStatusCancelled, "error": "replaced by a new schedule"}).Error
+		if err != nil {
+			return fmt.Errorf("failed to cancel scheduled changes: %w", err)
+		}
+
+		// An open window on an address that is already whitelisted needs no add
+		if req.ValidFrom != nil || !entry.IsWhitelisted {
+			executeAt := now
+			if req.ValidFrom != nil && req.ValidFrom.After(now) {
+				executeAt = *req.ValidFrom
+			}
+			changes = append(changes, models.ScheduledChange{Address: address, Action: batchActionAdd, ExecuteAt: executeAt})
+		}
+		if req.ValidUntil != nil {
+			changes = append(changes, models.ScheduledChange{Address: address, Action: batchActionRemove, ExecuteAt: *req.ValidUntil})
+		}
+		for i := range changes {
+			changes[i].Status = models.ScheduleStatusScheduled
+			changes[i].CreatedBy = actor
+		}
+		if len(changes) > 0 {
+			if err := tx.Create(&changes).Error; err != nil {
+				return fmt.Errorf("failed to store scheduled changes: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ss.logger.WithFields(logrus.Fields{
+		"address":     address,
+		"valid_from":  req.ValidFrom,
+		"valid_until": req.ValidUntil,
+		"actor":       actor,
+	}).Info("Whitelist window scheduled")
+	return &entry, changes, nil
+}
+
+// Upcoming returns scheduled changes due within the given duration, soonest
+// first. A zero duration returns every scheduled change.
+func (ss *SchedulerService) Upcoming(ctx context.Context, within time.Duration) ([]models.ScheduledChange, error) {
+	query := ss.db.WithContext(ctx).Where("status = ?", models.ScheduleStatusScheduled).Order("execute_at, id")
+	if within > 0 {
+		query = query.Where("execute_at <= ?", time.Now().Add(within))
+	}
+
+	var changes []models.ScheduledChange
+	if err := query.Find(&changes).Error; err != nil {
+		return nil, fmt.Errorf("failed to list scheduled changes: %w", err)
+	}
+	return changes, nil
+}
+
+// Cancel cancels a change that has not been submitted yet
+func (ss *SchedulerService) Cancel(ctx context.Context, id uint, actor string) error {
+	result := ss.db.WithContext(ctx).Model(&models.ScheduledChange{}).
+		Where("id = ? AND status = ?", id, models.ScheduleStatusScheduled).
+		Updates(map[string]interface{}{"status": models.ScheduleStatusCancelled, "error": "cancelled by " + actor})
+	if result.Error != nil {
+		return fmt.Errorf("failed to cancel scheduled change: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrScheduledChangeNotFound
+	}
+	return nil
+}
+
+// Start submits due changes every interval until ctx is cancelled
+func (ss *SchedulerService) Start(ctx context.Context) {
+	ticker := time.NewTicker(ss.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := ss.RunDue(ctx); err != nil && !errors.Is(err, ErrLockHeld) && ctx.Err() == nil {
+			ss.logger.WithError(err).Error("Failed to submit scheduled whitelist changes")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue queues every due change as one batch job and returns how many were
+// submitted. Only one replica runs at a time; ErrLockHeld is returned otherwise.
+func (ss *SchedulerService) RunDue(ctx context.Context) (int, error) {
+	release, err := acquireLock(ctx, ss.redis, scheduleLockKey, scheduleLockTTL)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	var due []models.ScheduledChange
+	err = ss.db.WithContext(ctx).
+		Where("status = ? AND execute_at <= ?", models.ScheduleStatusScheduled, time.Now()).
+		Order("execute_at, id").
+		Limit(min(maxDuePerRun, ss.batchService.config.MaxOperations)).
+		Find(&due).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to load due changes: %w", err)
+	}
+	if len(due) == 0 {
+		return 0, nil
+	}
+
+	// When an add and a removal for the same address are both due, only the
+	// later one matters
+	latest := make(map[string]int, len(due))
+	var superseded []uint
+	for i, change := range due {
+		if prev, ok := latest[change.Address]; ok {
+			superseded = append(superseded, due[prev].ID)
+		}
+		latest[change.Address] = i
+	}
+
+	var submit []models.ScheduledChange
+	operations := make([]BatchOperation, 0, len(latest))
+	for i, change := range due {
+		if latest[change.Address] != i {
+			continue
+		}
+		submit = append(submit, change)
+		operations = append(operations, BatchOperation{Address: change.Address, Action: change.Action})
+	}
+
+	now := time.Now()
+	if len(superseded) > 0 {
+		err := ss.db.WithContext(ctx).Model(&models.ScheduledChange{}).Where("id IN ?", superseded).
+			Updates(map[string]interface{}{"status": models.ScheduleStatusCancelled, "error": "superseded by a later change", "executed_at": now}).Error
+		if err != nil {
+			return 0, fmt.Errorf("failed to update scheduled changes: %w", err)
+		}
+	}
+
+	// Transient errors leave the changes scheduled for the next run. A batch
+	// with no valid operations cannot succeed, so its changes are failed.
+	job, validation, err := ss.batchService.Submit(ctx, operations, scheduleActor)
+	if err != nil && !errors.Is(err, ErrBatchEmpty) {
+		return 0, err
+	}
+
+	rejected := make(map[int]string, len(validation.Rejected))
+	for _, r := range validation.Rejected {
+		rejected[r.Index] = r.Reason
+	}
+
+	submitted := 0
+	err = ss.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, change := range submit {
+			updates := map[string]interface{}{"status": models.ScheduleStatusFailed, "error": rejected[i], "executed_at": now}
+			if _, ok := rejected[i]; !ok && job != nil {
+				updates = map[string]interface{}{"status": models.ScheduleStatusSubmitted, "batch_job_id": job.ID, "executed_at": now}
+				submitted++
+			}
+			if err := tx.Model(&models.ScheduledChange{}).Where("id = ?", change.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to update scheduled changes: %w", err)
+	}
+	if job == nil {
+		return 0, nil
+	}
+
+	ss.logger.WithFields(logrus.Fields{
+		"job_id":     job.ID,
+		"submitted":  submitted,
+		"superseded": len(superseded),
+	}).Info("Scheduled whitelist changes submitted")
+	return submitted, nil
+}
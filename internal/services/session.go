@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"whitelist-token-backend/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Session errors returned to handlers
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrSessionRevoked      = errors.New("session has been revoked")
+)
+
+// TokenPair is returned on login and on every refresh
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token"`
+	TokenType        string    `json:"token_type"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	Role             string    `json:"role"`
+}
+
+// Redis key layout for sessions. A session is a refresh token family: every
+// refresh rotates the token but keeps the family ID, which is also carried
+// by access tokens as the "sid" claim so revoking the family revokes them too.
+func refreshTokenKey(hash string) string    { return "auth:refresh:" + hash }
+func sessionFamilyKey(family string) string { return "auth:family:" + family }
+func userSessionsKey(address string) string { return "auth:sessions:" + address }
+
+// StartSession creates a new refresh token family for a user and returns its first token pair
+func (as *AuthService) StartSession(ctx context.Context, user *models.User) (*TokenPair, error) {
+	family, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := as.redis.TxPipeline()
+	pipe.Set(ctx, sessionFamilyKey(family), user.Address, as.config.RefreshTokenTTL)
+	pipe.SAdd(ctx, userSessionsKey(user.Address), family)
+	pipe.Expire(ctx, userSessionsKey(user.Address), as.config.RefreshTokenTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return as.issueTokenPair(ctx, user, family)
+}
+
+// RefreshSession rotates a refresh token. Presenting a token that was already
+// rotated revokes the whole family, since only a stolen copy would be reused.
+func (as *AuthService) RefreshSession(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	key := refreshTokenKey(hashToken(refreshToken))
+
+	record, err := as.redis.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load refresh token: %w", err)
+	}
+	family, address := record["family"], record["address"]
+	if family == "" || address == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// HINCRBY is atomic, so of two concurrent refreshes only one sees 1
+	uses, err := as.redis.HIncrBy(ctx, key, "uses", 1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark refresh token used: %w", err)
+	}
+	if uses > 1 {
+		if err := as.revokeFamily(ctx, family, address); err != nil {
+			as.logger.WithError(err).WithField("address", address).Error("Failed to revoke session after refresh token reuse")
+		}
+		as.logger.WithField("address", address).Warn("Refresh token reuse detected, session revoked")
+		return nil, ErrRefreshTokenReused
+	}
+
+	active, err := as.redis.Exists(ctx, sessionFamilyKey(family)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check session: %w", err)
+	}
+	if active == 0 {
+		return nil, ErrSessionRevoked
+	}
+
+	var user models.User
+	if err := as.db.WithContext(ctx).Where("address = ?", address).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	if !user.IsActive {
+		if err := as.revokeFamily(ctx, family, address); err != nil {
+			return nil, err
+		}
+		return nil, ErrSessionRevoked
+	}
+
+	// The index must outlive every session in it, or RevokeAllSessions misses them
+	pipe := as.redis.TxPipeline()
+	pipe.Expire(ctx, sessionFamilyKey(family), as.config.RefreshTokenTTL)
+	pipe.Expire(ctx, userSessionsKey(address), as.config.RefreshTokenTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to extend session: %w", err)
+	}
+
+	return as.issueTokenPair(ctx, &user, family)
+}
+
+// Logout revokes the session the refresh token belongs to
+func (as *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	record, err := as.redis.HGetAll(ctx, refreshTokenKey(hashToken(refreshToken))).Result()
+	if err != nil {
+		return fmt.Errorf("failed to load refresh token: %w", err)
+	}
+	if record["family"] == "" {
+		return ErrInvalidRefreshToken
+	}
+
+	return as.revokeFamily(ctx, record["family"], record["address"])
+}
+
+// RevokeAllSessions revokes every session of an address and returns how many were active
+func (as *AuthService) RevokeAllSessions(ctx context.Context, address string) (int, error) {
+	families, err := as.redis.SMembers(ctx, userSessionsKey(address)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	revoked := 0
+	for _, family := range families {
+		deleted, err := as.redis.Del(ctx, sessionFamilyKey(family)).Result()
+		if err != nil {
+			return revoked, fmt.Errorf("failed to revoke session: %w", err)
+		}
+		revoked += int(deleted)
+	}
+
+	if err := as.redis.Del(ctx, userSessionsKey(address)).Err(); err != nil {
+		return revoked, fmt.Errorf("failed to clear session index: %w", err)
+	}
+
+	as.logger.WithField("address", address).WithField("sessions", revoked).Info("Revoked all sessions")
+	return revoked, nil
+}
+
+// CheckSession reports ErrSessionRevoked when the access token's session was logged out or revoked
+func (as *AuthService) CheckSession(ctx context.Context, claims *Claims) error {
+	active, err := as.redis.Exists(ctx, sessionFamilyKey(claims.SessionID)).Result()
+	if err != nil {
+		return fmt.Errorf("failed to check session: %w", err)
+	}
+	if active == 0 {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+func (as *AuthService) issueTokenPair(ctx context.Context, user *models.User, family string) (*TokenPair, error) {
+	role, err := as.rbac.PrimaryRole(ctx, user.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, claims, err := as.IssueAccessToken(user, role, family)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	key := refreshTokenKey(hashToken(refreshToken))
+	pipe := as.redis.TxPipeline()
+	pipe.HSet(ctx, key, map[string]interface{}{
+		"family":  family,
+		"address": user.Address,
+		"uses":    0,
+	})
+	pipe.Expire(ctx, key, as.config.RefreshTokenTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		TokenType:        "Bearer",
+		ExpiresAt:        claims.ExpiresAt.Time,
+		RefreshExpiresAt: time.Now().Add(as.config.RefreshTokenTTL),
+		Role:             claims.Role,
+	}, nil
+}
+
+func (as *AuthService) revokeFamily(ctx context.Context, family, address string) error {
+	pipe := as.redis.TxPipeline()
+	pipe.Del(ctx, sessionFamilyKey(family))
+	if address != "" {
+		pipe.SRem(ctx, userSessionsKey(address), family)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the SHA-256 of a token so raw tokens are never stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	siweHeaderSuffix = " wants you to sign in with your Ethereum account:"
+	siweVersion      = "1"
+	nonceAlphabet    = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	nonceLength      = 17
+)
+
+// SIWEMessage represents an EIP-4361 Sign-In with Ethereum message
+type SIWEMessage struct {
+	Domain         string
+	Address        string
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// String renders the message in the exact format wallets are asked to sign
+func (m *SIWEMessage) String() string {
+	var b strings.Builder
+
+	b.WriteString(m.Domain + siweHeaderSuffix + "\n")
+	b.WriteString(m.Address + "\n")
+	b.WriteString("\n")
+	if m.Statement != "" {
+		b.WriteString(m.Statement + "\n")
+	}
+	b.WriteString("\n")
+	b.WriteString("URI: " + m.URI + "\n")
+	b.WriteString("Version: " + m.Version + "\n")
+	b.WriteString("Chain ID: " + strconv.FormatInt(m.ChainID, 10) + "\n")
+	b.WriteString("Nonce: " + m.Nonce + "\n")
+	b.WriteString("Issued At: " + m.IssuedAt.UTC().Format(time.RFC3339))
+	if m.ExpirationTime != nil {
+		b.WriteString("\nExpiration Time: " + m.ExpirationTime.UTC().Format(time.RFC3339))
+	}
+	if m.NotBefore != nil {
+		b.WriteString("\nNot Before: " + m.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if m.RequestID != "" {
+		b.WriteString("\nRequest ID: " + m.RequestID)
+	}
+	if len(m.Resources) > 0 {
+		b.WriteString("\nResources:")
+		for _, resource := range m.Resources {
+			b.WriteString("\n- " + resource)
+		}
+	}
+
+	return b.String()
+}
+
+// ParseSIWEMessage parses an EIP-4361 message as produced by wallets and SIWE libraries
+func ParseSIWEMessage(raw string) (*SIWEMessage, error) {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	if len(lines) < 7 {
+		return nil, fmt.Errorf("message is too short")
+	}
+
+	msg := &SIWEMessage{}
+
+	if !strings.HasSuffix(lines[0], siweHeaderSuffix) {
+		return nil, fmt.Errorf("invalid message header")
+	}
+	msg.Domain = strings.TrimSuffix(lines[0], siweHeaderSuffix)
+	if msg.Domain == "" {
+		return nil, fmt.Errorf("missing domain")
+	}
+
+	if !common.IsHexAddress(lines[1]) || !strings.HasPrefix(lines[1], "0x") {
+		return nil, fmt.Errorf("invalid address")
+	}
+	msg.Address = lines[1]
+
+	// The address is followed by a blank line, an optional statement and another blank line
+	i := 2
+	if lines[i] != "" {
+		return nil, fmt.Errorf("expected blank line after address")
+	}
+	i++
+	if lines[i] != "" && !strings.HasPrefix(lines[i], "URI: ") {
+		msg.Statement = lines[i]
+		i++
+	}
+	if i < len(lines) && lines[i] == "" {
+		i++
+	}
+
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "URI: "):
+			msg.URI = strings.TrimPrefix(line, "URI: ")
+		case strings.HasPrefix(line, "Version: "):
+			msg.Version = strings.TrimPrefix(line, "Version: ")
+		case strings.HasPrefix(line, "Chain ID: "):
+			chainID, err := strconv.ParseInt(strings.TrimPrefix(line, "Chain ID: "), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid chain ID: %w", err)
+			}
+			msg.ChainID = chainID
+		case strings.HasPrefix(line, "Nonce: "):
+			msg.Nonce = strings.TrimPrefix(line, "Nonce: ")
+		case strings.HasPrefix(line, "Issued At: "):
+			issuedAt, err := time.Parse(time.RFC3339, strings.TrimPrefix(line, "Issued At: "))
+			if err != nil {
+				return nil, fmt.Errorf("invalid issued-at time: %w", err)
+			}
+			msg.IssuedAt = issuedAt
+		case strings.HasPrefix(line, "Expiration Time: "):
+			expiration, err := time.Parse(time.RFC3339, strings.TrimPrefix(line, "Expiration Time: "))
+			if err != nil {
+				return nil, fmt.Errorf("invalid expiration time: %w", err)
+			}
+			msg.ExpirationTime = &expiration
+		case strings.HasPrefix(line, "Not Before: "):
+			notBefore, err := time.Parse(time.RFC3339, strings.TrimPrefix(line, "Not Before: "))
+			if err != nil {
+				return nil, fmt.Errorf("invalid not-before time: %w", err)
+			}
+			msg.NotBefore = &notBefore
+		case strings.HasPrefix(line, "Request ID: "):
+			msg.RequestID = strings.TrimPrefix(line, "Request ID: ")
+		case line == "Resources:":
+			for i+1 < len(lines) && strings.HasPrefix(lines[i+1], "- ") {
+				i++
+				msg.Resources = append(msg.Resources, strings.TrimPrefix(lines[i], "- "))
+			}
+		case line == "":
+			// Tolerate a trailing newline
+		default:
+			return nil, fmt.Errorf("unexpected line %q", line)
+		}
+	}
+
+	if msg.URI == "" {
+		return nil, fmt.Errorf("missing URI")
+	}
+	if msg.Version != siweVersion {
+		return nil, fmt.Errorf("unsupported version %q", msg.Version)
+	}
+	if msg.ChainID == 0 {
+		return nil, fmt.Errorf("missing chain ID")
+	}
+	if len(msg.Nonce) < 8 {
+		return nil, fmt.Errorf("nonce must be at least 8 characters")
+	}
+	if msg.IssuedAt.IsZero() {
+		return nil, fmt.Errorf("missing issued-at time")
+	}
+
+	return msg, nil
+}
+
+// generateNonce returns a random alphanumeric nonce as required by EIP-4361
+func generateNonce() (string, error) {
+	max := big.NewInt(int64(len(nonceAlphabet)))
+	nonce := make([]byte, nonceLength)
+	for i := range nonce {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate nonce: %w", err)
+		}
+		nonce[i] = nonceAlphabet[n.Int64()]
+	}
+	return string(nonce), nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSIWEMessage(t *testing.T) {
+	issuedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiresAt := issuedAt.Add(10 * time.Minute)
+	full := &SIWEMessage{
+		Domain:         "app.example.com",
+		Address:        "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266",
+		Statement:      "Sign in to the whitelist.",
+		URI:            "https://app.example.com",
+		Version:        siweVersion,
+		ChainID:        1,
+		Nonce:          "abcdefgh12345678",
+		IssuedAt:       issuedAt,
+		ExpirationTime: &expiresAt,
+		NotBefore:      &issuedAt,
+		RequestID:      "req-1",
+		Resources:      []string{"https://app.example.com/terms", "ipfs://bafy"},
+	}
+	minimal := &SIWEMessage{
+		Domain:   "localhost:3000",
+		Address:  "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266",
+		URI:      "http://localhost:3000",
+		Version:  siweVersion,
+		ChainID:  31337,
+		Nonce:    "abcdefgh",
+		IssuedAt: issuedAt,
+	}
+
+	tests := []struct {
+		name    string
+		raw     string
+		want    *SIWEMessage
+		wantErr string
+	}{
+		{name: "all fields", raw: full.String(), want: full},
+		{name: "without statement", raw: minimal.String(), want: minimal},
+		{name: "CRLF and trailing newline", raw: strings.ReplaceAll(minimal.String(), "\n", "\r\n") + "\r\n", want: minimal},
+		{name: "too short", raw: "localhost wants you to sign in with your Ethereum account:", wantErr: "too short"},
+		{name: "bad header", raw: strings.Replace(minimal.String(), "wants you to sign in", "asks you to sign in", 1), wantErr: "invalid message header"},
+		{name: "missing domain", raw: strings.TrimPrefix(minimal.String(), "localhost:3000"), wantErr: "missing domain"},
+		{name: "invalid address", raw: strings.Replace(minimal.String(), minimal.Address, "0x1234", 1), wantErr: "invalid address"},
+		{name: "address without 0x", raw: strings.Replace(minimal.String(), minimal.Address, minimal.Address[2:], 1), wantErr: "invalid address"},
+		{name: "unsupported version", raw: strings.Replace(minimal.String(), "Version: 1", "Version: 2", 1), wantErr: "unsupported version"},
+		{name: "invalid chain ID", raw: strings.Replace(minimal.String(), "Chain ID: 31337", "Chain ID: mainnet", 1), wantErr: "invalid chain ID"},
+		{name: "short nonce", raw: strings.Replace(minimal.String(), "Nonce: abcdefgh", "Nonce: abc", 1), wantErr: "nonce must be at least 8 characters"},
+		{name: "invalid issued-at", raw: strings.Replace(minimal.String(), "2024-01-02T03:04:05Z", "yesterday", 1), wantErr: "invalid issued-at time"},
+		{name: "unexpected line", raw: minimal.String() + "\nSomething: else", wantErr: "unexpected line"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSIWEMessage(tt.raw)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseSIWEMessage() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSIWEMessage() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseSIWEMessage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
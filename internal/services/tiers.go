@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"whitelist-token-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Tier errors returned to handlers
+var (
+	ErrTierNotFound    = errors.New("allocation tier not found")
+	ErrTierInUse       = errors.New("allocation tier is assigned to whitelist entries")
+	ErrInvalidTierName = errors.New("tier names must be 1-32 lowercase letters, digits, '-' or '_'")
+)
+
+var tierNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// ListTiers returns all allocation tiers ordered by name
+func (ws *WhitelistService) ListTiers(ctx context.Context) ([]models.AllocationTier, error) {
+	var tiers []models.AllocationTier
+	if err := ws.db.WithContext(ctx).Order("name").Find(&tiers).Error; err != nil {
+		return nil, fmt.Errorf("failed to list allocation tiers: %w", err)
+	}
+	return tiers, nil
+}
+
+// SaveTier creates a tier or updates the cap and description of an existing one
+func (ws *WhitelistService) SaveTier(ctx context.Context, name, limit, description string) (*models.AllocationTier, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !tierNamePattern.MatchString(name) {
+		return nil, ErrInvalidTierName
+	}
+	amount, err := parseAllocation(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var tier models.AllocationTier
+	err = ws.db.WithContext(ctx).Where("name = ?", name).First(&tier).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to load allocation tier: %w", err)
+	}
+	tier.Name = name
+	tier.Cap = amount
+	tier.Description = description
+	if err := ws.db.WithContext(ctx).Save(&tier).Error; err != nil {
+		return nil, fmt.Errorf("failed to save allocation tier: %w", err)
+	}
+
+	ws.logger.WithField("tier", name).WithField("cap", amount).Info("Allocation tier saved")
+	return &tier, nil
+}
+
+// DeleteTier removes a tier that no whitelist entry is assigned to
+func (ws *WhitelistService) DeleteTier(ctx context.Context, name string) error {
+	var assigned int64
+	if err := ws.db.WithContext(ctx).Model(&models.WhitelistEntry{}).Where("tier = ?", name).Count(&assigned).Error; err != nil {
+		return fmt.Errorf("failed to count tier entries: %w", err)
+	}
+	if assigned > 0 {
+		return fmt.Errorf("%w (%d entries)", ErrTierInUse, assigned)
+	}
+
+	result := ws.db.WithContext(ctx).Where("name = ?", name).Delete(&models.AllocationTier{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete allocation tier: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrTierNotFound
+	}
+	return nil
+}
+
+// AssignTier moves an entry to a tier. An empty tier clears the assignment.
+func (ws *WhitelistService) AssignTier(ctx context.Context, address, tier string) (*models.WhitelistEntry, error) {
+	address, err := NormalizeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	tier = strings.ToLower(strings.TrimSpace(tier))
+	if err := ws.checkTier(ctx, tier); err != nil {
+		return nil, err
+	}
+
+	result := ws.db.WithContext(ctx).Model(&models.WhitelistEntry{}).Where("address = ?", address).Update("tier", tier)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to assign tier: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrWhitelistEntryNotFound
+	}
+	return ws.Get(ctx, address)
+}
+
+// Status returns an address's whitelist state and how much it can still buy.
+// The cap is the entry's own allocation, else its tier's cap, else the sale's
+// maximum purchase.
+func (ws *WhitelistService) Status(ctx context.Context, address string) (*models.WhitelistStatusDTO, error) {
+	address, err := NormalizeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	whitelisted, err := ws.IsWhitelisted(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	status := &models.WhitelistStatusDTO{
+		Address:             address,
+		IsWhitelisted:       whitelisted,
+		MaxAllocation:       "0",
+		UsedAllocation:      "0",
+		RemainingAllocation: "0",
+	}
+
+	var entry models.WhitelistEntry
+	err = ws.db.WithContext(ctx).Where("address = ?", address).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load whitelist entry: %w", err)
+	}
+
+	limits, err := ws.allocationLimits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	limit := limits.effective(entry)
+	used := parseAmount(entry.UsedAllocation)
+
+	status.MaxAllocation = limit.String()
+	status.UsedAllocation = used.String()
+	status.Tier = entry.Tier
+	status.AddedAt = entry.AddedAt
+	if whitelisted && limit.Cmp(used) > 0 {
+		status.RemainingAllocation = new(big.Int).Sub(limit, used).String()
+	}
+	return status, nil
+}
+
+// SyncUsedAllocation recomputes UsedAllocation from confirmed purchases for
+// the given addresses, or for every entry when none are given. It returns the
+// number of entries updated.
+func (ws *WhitelistService) SyncUsedAllocation(ctx context.Context, addresses ...string) (int64, error) {
+	query := ws.db.WithContext(ctx).Model(&models.WhitelistEntry{})
+	if len(addresses) > 0 {
+		query = query.Where("address IN ?", addresses)
+	} else {
+		query = query.Session(&gorm.Session{AllowGlobalUpdate: true})
+	}
+
+	result := query.Update("used_allocation", gorm.Expr(
+		`COALESCE((SELECT SUM(p.token_amount) FROM purchases p
+			WHERE p.buyer_address = whitelist_entries.address AND p.status = ? AND p.deleted_at IS NULL), 0)`,
+		models.PurchaseStatusConfirmed,
+	))
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to sync used allocation: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// checkTier returns ErrTierNotFound unless tier is empty or an existing tier
+func (ws *WhitelistService) checkTier(ctx context.Context, tier string) error {
+	if tier == "" {
+		return nil
+	}
+	if err := ws.db.WithContext(ctx).Where("name = ?", tier).First(&models.AllocationTier{}).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTierNotFound
+		}
+		return fmt.Errorf("failed to load allocation tier: %w", err)
+	}
+	return nil
+}
+
+// tierCaps returns the cap of every tier by name
+func (ws *WhitelistService) tierCaps(ctx context.Context) (map[string]*big.Int, error) {
+	tiers, err := ws.ListTiers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	caps := make(map[string]*big.Int, len(tiers))
+	for _, tier := range tiers {
+		caps[tier.Name] = parseAmount(tier.Cap)
+	}
+	return caps, nil
+}
+
+// allocationLimits resolves the allocation an entry is held to: its own
+// allocation, else its tier's cap, else the active sale's maximum purchase.
+// Status, merkle leaves and merkle exports all use it, so a proof grants
+// exactly the cap the API reports.
+type allocationLimits struct {
+	caps        map[string]*big.Int
+	maxPurchase *big.Int
+}
+
+func (ws *WhitelistService) allocationLimits(ctx context.Context) (*allocationLimits, error) {
+	caps, err := ws.tierCaps(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var sale models.SaleConfig
+	err = ws.db.WithContext(ctx).Where("is_active = ?", true).Order("id DESC").First(&sale).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to load sale config: %w", err)
+	}
+	return &allocationLimits{caps: caps, maxPurchase: parseAmount(sale.MaxPurchase)}, nil
+}
+
+// effective returns the allocation entry is held to
+func (l *allocationLimits) effective(entry models.WhitelistEntry) *big.Int {
+	if allocation := parseAmount(entry.MaxAllocation); allocation.Sign() > 0 {
+		return allocation
+	}
+	if limit, ok := l.caps[entry.Tier]; ok && limit.Sign() > 0 {
+		return new(big.Int).Set(limit)
+	}
+	return new(big.Int).Set(l.maxPurchase)
+}
+
+// parseAmount parses a decimal column, treating empty or invalid values as zero
+func parseAmount(s string) *big.Int {
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return new(big.Int)
+	}
+	return v
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"whitelist-token-backend/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken is returned when an access token fails verification
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Claims are the JWT claims carried by access tokens
+type Claims struct {
+	Address   string `json:"address"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// tokenKeys holds the key material for the configured signing method
+type tokenKeys struct {
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// loadTokenKeys prepares signing and verification keys for HS256, RS256 or EdDSA
+func loadTokenKeys(method, secret, privateKeyPath, publicKeyPath string) (*tokenKeys, error) {
+	switch method {
+	case "", jwt.SigningMethodHS256.Alg():
+		if secret == "" {
+			return nil, fmt.Errorf("JWT secret is required for HS256")
+		}
+		return &tokenKeys{
+			method:    jwt.SigningMethodHS256,
+			signKey:   []byte(secret),
+			verifyKey: []byte(secret),
+		}, nil
+
+	case jwt.SigningMethodRS256.Alg():
+		privatePEM, err := os.ReadFile(privateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read RSA private key: %w", err)
+		}
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RSA private key: %w", err)
+		}
+
+		publicKey := &privateKey.PublicKey
+		if publicKeyPath != "" {
+			publicPEM, err := os.ReadFile(publicKeyPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read RSA public key: %w", err)
+			}
+			if publicKey, err = jwt.ParseRSAPublicKeyFromPEM(publicPEM); err != nil {
+				return nil, fmt.Errorf("failed to parse RSA public key: %w", err)
+			}
+		}
+
+		return &tokenKeys{
+			method:    jwt.SigningMethodRS256,
+			signKey:   privateKey,
+			verifyKey: publicKey,
+		}, nil
+
+	case jwt.SigningMethodEdDSA.Alg():
+		privatePEM, err := os.ReadFile(privateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Ed25519 private key: %w", err)
+		}
+		privateKey, err := jwt.ParseEdPrivateKeyFromPEM(privatePEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Ed25519 private key: %w", err)
+		}
+
+		var publicKey crypto.PublicKey = privateKey.(ed25519.PrivateKey).Public()
+		if publicKeyPath != "" {
+			publicPEM, err := os.ReadFile(publicKeyPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read Ed25519 public key: %w", err)
+			}
+			if publicKey, err = jwt.ParseEdPublicKeyFromPEM(publicPEM); err != nil {
+				return nil, fmt.Errorf("failed to parse Ed25519 public key: %w", err)
+			}
+		}
+
+		return &tokenKeys{
+			method:    jwt.SigningMethodEdDSA,
+			signKey:   privateKey,
+			verifyKey: publicKey,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported JWT signing method %q", method)
+	}
+}
+
+// IssueAccessToken mints a signed access token for a user within a session
+func (as *AuthService) IssueAccessToken(user *models.User, role, sessionID string) (string, *Claims, error) {
+	jti, err := randomID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		Address:   user.Address,
+		Role:      role,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   user.Address,
+			Issuer:    as.config.Issuer,
+			Audience:  jwt.ClaimStrings{as.config.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(as.config.AccessTokenTTL)),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(as.keys.method, claims).SignedString(as.keys.signKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return token, claims, nil
+}
+
+// ValidateAccessToken verifies signature, expiry, issuer and audience of an access token
+func (as *AuthService) ValidateAccessToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return as.keys.verifyKey, nil
+	},
+		jwt.WithValidMethods([]string{as.keys.method.Alg()}),
+		jwt.WithIssuer(as.config.Issuer),
+		jwt.WithAudience(as.config.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if claims.Address == "" || claims.Address != claims.Subject {
+		return nil, fmt.Errorf("%w: subject mismatch", ErrInvalidToken)
+	}
+	if claims.SessionID == "" || claims.ID == "" {
+		return nil, fmt.Errorf("%w: missing session", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// randomID returns a random 128-bit identifier encoded as hex
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
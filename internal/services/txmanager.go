@@ -0,0 +1,966 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"whitelist-token-backend/internal/models"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	signerLockPrefix = "lock:tx:signer:"
+	// signerLockTTL bounds how long a crashed instance can block the signer.
+	// Sends are cut off at half of it so the lock never expires mid-send.
+	signerLockTTL = 30 * time.Second
+	// signerLockWait is how long Send waits for other sends to finish
+	signerLockWait = 20 * time.Second
+	txTrackLockKey = "lock:tx:track"
+	txTrackLockTTL = 2 * time.Minute
+	// maxNonceRetries bounds how often Send picks a new nonce after losing
+	// one to a transaction sent with the key from elsewhere
+	maxNonceRetries = 3
+)
+
+// Transaction errors returned to handlers. ErrFeeCapExceeded is returned
+// when the network's base fee, or gas price on chains without one, is above
+// the configured fee cap.
+var (
+	ErrFeeCapExceeded    = errors.New("network fees are above the configured fee cap")
+	ErrGasLimitExceeded  = errors.New("transaction gas exceeds the block gas limit")
+	ErrTxCostExceeded    = errors.New("transaction cost exceeds the configured ceiling")
+	ErrExecutionReverted = errors.New("transaction would revert")
+	ErrTxNotFound        = errors.New("transaction not found")
+)
+
+// errNonceTaken is returned by sendOnce when the node already has another
+// transaction with the assigned nonce
+var errNonceTaken = errors.New("nonce already used by another transaction")
+
+// TxManagerConfig controls fees and the pending transaction tracker. Nil
+// caps are unlimited.
+type TxManagerConfig struct {
+	PollInterval    time.Duration
+	MaxFeeCap       *big.Int // Highest max fee per gas, or gas price, in wei
+	MaxTipCap       *big.Int // Highest priority fee per gas in wei
+	MaxTxCost       *big.Int // Highest gas limit times max fee per gas in wei
+	GasMultiplier   float64  // Safety margin applied to gas estimates
+	BumpAfterBlocks uint64   // Blocks a transaction may stay unmined before it is replaced with higher fees
+	BumpPercent     int64    // Fee increase of each replacement; nodes require at least 10
+	Confirmations   uint64   // Blocks required on top of a mined transaction before its outcome is final
+}
+
+// txFees are the fees of a transaction. Legacy transactions, on chains
+// without a base fee, pay FeeCap as their gas price and TipCap equals it.
+type txFees struct {
+	TipCap  *big.Int
+	FeeCap  *big.Int
+	Dynamic bool
+}
+
+// TxRequest is a contract call to send from the signer key. Params are the
+// arguments Data encodes, stored with the record for display.
+type TxRequest struct {
+	To     common.Address
+	Method string
+	Params []interface{}
+	Data   []byte
+}
+
+// TxCompleteFunc is called when a transaction reaches a final status
+type TxCompleteFunc func(ctx context.Context, record *models.Transaction)
+
+// GasEstimate is the gas limit and worst-case cost a transaction is sent with
+type GasEstimate struct {
+	Method               string `json:"method"`
+	To                   string `json:"to"`
+	EstimatedGas         uint64 `json:"estimated_gas"`
+	GasLimit             uint64 `json:"gas_limit"` // EstimatedGas with the safety multiplier, up to the block gas limit
+	BlockGasLimit        uint64 `json:"block_gas_limit"`
+	MaxFeePerGas         string `json:"max_fee_per_gas"` // Gas price for legacy transactions
+	MaxPriorityFeePerGas string `json:"max_priority_fee_per_gas"`
+	MaxCost              string `json:"max_cost"` // GasLimit times MaxFeePerGas, in wei
+	MaxCostCeiling       string `json:"max_cost_ceiling,omitempty"`
+}
+
+// TxManager is the single outbound transaction queue for the signer key.
+// Sends are serialised across replicas with a Redis lock, nonces are assigned
+// from Postgres and every signed transaction is stored before it is
+// broadcast, so a crash or a dropped transaction never reuses or skips a
+// nonce.
+type TxManager struct {
+	db                *gorm.DB
+	redis             *redis.Client
+	blockchainService *BlockchainService
+	config            TxManagerConfig
+	onComplete        []TxCompleteFunc
+	logger            *logrus.Logger
+}
+
+// NewTxManager creates a new transaction manager
+func NewTxManager(
+	db *gorm.DB,
+	redis *redis.Client,
+	blockchainService *BlockchainService,
+	config TxManagerConfig,
+	logger *logrus.Logger,
+) *TxManager {
+	if config.PollInterval <= 0 {
+		config.PollInterval = 15 * time.Second
+	}
+	if config.GasMultiplier < 1 {
+		config.GasMultiplier = 1
+	}
+	if config.BumpAfterBlocks == 0 {
+		config.BumpAfterBlocks = 5
+	}
+	if config.BumpPercent < 10 {
+		config.BumpPercent = 10
+	}
+	return &TxManager{
+		db:                db,
+		redis:             redis,
+		blockchainService: blockchainService,
+		config:            config,
+		logger:            logger,
+	}
+}
+
+// OnComplete registers fn to be called whenever a transaction is confirmed
+// or reverted with Confirmations blocks on top, fails or is dropped. It must
+// be called before Start.
+func (tm *TxManager) OnComplete(fn TxCompleteFunc) {
+	tm.onComplete = append(tm.onComplete, fn)
+}
+
+// Get returns a transaction record by ID
+func (tm *TxManager) Get(ctx context.Context, id uint) (*models.Transaction, error) {
+	var record models.Transaction
+	if err := tm.db.WithContext(ctx).First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTxNotFound
+		}
+		return nil, fmt.Errorf("failed to load transaction: %w", err)
+	}
+	return &record, nil
+}
+
+// Send assigns the next nonce, signs and broadcasts a transaction and
+// returns its record. When the node cannot be reached the transaction stays
+// pending and is rebroadcast by Track, since it may already have been sent.
+func (tm *TxManager) Send(ctx context.Context, req TxRequest) (*models.Transaction, *types.Transaction, error) {
+	from, err := tm.signer()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	release, err := waitForLock(ctx, tm.redis, signerLockPrefix+from.Hex(), signerLockTTL, signerLockWait)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	sendCtx, cancel := context.WithTimeout(ctx, signerLockTTL/2)
+	defer cancel()
+
+	for attempt := 0; ; attempt++ {
+		record, signed, err := tm.sendOnce(sendCtx, from, req)
+		if errors.Is(err, errNonceTaken) && attempt < maxNonceRetries {
+			tm.logger.WithField("nonce", record.Nonce).Warn("Nonce was used by another transaction, retrying with the next one")
+			continue
+		}
+		return record, signed, err
+	}
+}
+
+// Estimate returns the gas limit and cost a transaction would be sent with.
+// When it would be refused, the estimate is returned together with
+// ErrGasLimitExceeded or ErrTxCostExceeded.
+func (tm *TxManager) Estimate(ctx context.Context, req TxRequest) (*GasEstimate, error) {
+	from, err := tm.signer()
+	if err != nil {
+		return nil, err
+	}
+	estimate, _, _, err := tm.estimate(ctx, from, req)
+	return estimate, err
+}
+
+// WaitMined waits until any version of a sent transaction is mined with
+// Confirmations blocks on top and returns its receipt, following replacements
+// Track makes meanwhile
+func (tm *TxManager) WaitMined(ctx context.Context, record *models.Transaction) (*types.Receipt, error) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		if err := tm.db.WithContext(ctx).First(record, record.ID).Error; err != nil {
+			return nil, fmt.Errorf("failed to load transaction: %w", err)
+		}
+		if record.Status == models.TxStatusDropped || record.Status == models.TxStatusFailed {
+			return nil, fmt.Errorf("transaction %s %s: %s", record.TxHash, record.Status, record.Error)
+		}
+
+		receipt, err := tm.receipt(ctx, record)
+		if err != nil {
+			return nil, err
+		}
+		if receipt != nil {
+			if record.Status == models.TxStatusPending || record.Status == models.TxStatusMined {
+				head, err := tm.blockchainService.BlockNumber(ctx)
+				if err != nil {
+					return nil, fmt.Errorf("failed to get block number: %w", err)
+				}
+				if err := tm.recordReceipt(ctx, record, receipt, head); err != nil {
+					return nil, err
+				}
+			}
+			if record.Status != models.TxStatusMined {
+				return receipt, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Start tracks pending transactions every poll interval until ctx is cancelled
+func (tm *TxManager) Start(ctx context.Context) {
+	if tm.blockchainService.privateKey == nil {
+		tm.logger.Info("Transaction tracker disabled: no private key")
+		return
+	}
+
+	ticker := time.NewTicker(tm.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := tm.Track(ctx); err != nil && !errors.Is(err, ErrLockHeld) && ctx.Err() == nil {
+			tm.logger.WithError(err).Error("Failed to track pending transactions")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Track updates pending and mined transactions from their receipts and
+// marks those whose nonce was mined by another transaction as dropped.
+// Mined transactions are final once Confirmations blocks are on top of them;
+// those whose receipt disappears in a reorg go back to pending. Transactions
+// unmined for BumpAfterBlocks are replaced with higher fees and the same
+// nonce; the others are rebroadcast if the node no longer knows them. Only
+// one replica tracks at a time; ErrLockHeld is returned otherwise.
+func (tm *TxManager) Track(ctx context.Context) error {
+	from, err := tm.signer()
+	if err != nil {
+		return err
+	}
+
+	release, err := acquireLock(ctx, tm.redis, txTrackLockKey, txTrackLockTTL)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	var pending []models.Transaction
+	err = tm.db.WithContext(ctx).
+		Where("from_address = ? AND status IN ?", from.Hex(), []string{models.TxStatusPending, models.TxStatusMined}).
+		Order("nonce, id").
+		Find(&pending).Error
+	if err != nil {
+		return fmt.Errorf("failed to load pending transactions: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	// Read before the receipts, so a transaction mined in between is not
+	// mistaken for one whose nonce was taken
+	minedNonce, err := tm.blockchainService.client.NonceAt(ctx, from, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get signer nonce: %w", err)
+	}
+	head, err := tm.blockchainService.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get latest header: %w", err)
+	}
+
+	for i := range pending {
+		record := &pending[i]
+		receipt, err := tm.receipt(ctx, record)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case receipt != nil:
+			if err := tm.recordReceipt(ctx, record, receipt, head.Number.Uint64()); err != nil {
+				return err
+			}
+		case record.Status == models.TxStatusMined:
+			if err := tm.unmine(ctx, record); err != nil {
+				return err
+			}
+		case record.Nonce < minedNonce:
+			tm.logger.WithFields(logrus.Fields{
+				"tx_hash": record.TxHash,
+				"nonce":   record.Nonce,
+			}).Warn("Transaction dropped, its nonce was used by another transaction")
+			if err := tm.finish(ctx, record, models.TxStatusDropped, errNonceTaken.Error()); err != nil {
+				return err
+			}
+		case head.Number.Uint64() >= record.SentBlock+tm.config.BumpAfterBlocks:
+			if err := tm.bump(ctx, record, head); err != nil {
+				tm.logger.WithError(err).WithField("tx_hash", record.TxHash).Warn("Failed to replace stuck transaction")
+			}
+		default:
+			if err := tm.rebroadcastIfMissing(ctx, record); err != nil {
+				tm.logger.WithError(err).WithField("tx_hash", record.TxHash).Warn("Failed to rebroadcast pending transaction")
+			}
+		}
+	}
+	return nil
+}
+
+// sendOnce sends req with the next free nonce. The record is written before
+// broadcasting; a nonce the node rejected without using is released again.
+func (tm *TxManager) sendOnce(ctx context.Context, from common.Address, req TxRequest) (*models.Transaction, *types.Transaction, error) {
+	nonce, next, err := tm.assignNonce(ctx, from)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	estimate, fees, head, err := tm.estimate(ctx, from, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	params, err := json.Marshal(req.Params)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode %s params: %w", req.Method, err)
+	}
+
+	record := models.Transaction{
+		FromAddress: from.Hex(),
+		Nonce:       nonce,
+		ToAddress:   req.To.Hex(),
+		Method:      req.Method,
+		Params:      string(params),
+		GasLimit:    estimate.GasLimit,
+		Status:      models.TxStatusPending,
+		Attempts:    1,
+	}
+	signed, err := tm.sign(ctx, &record, req.Data, fees, head)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = tm.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&record).Error; err != nil {
+			return fmt.Errorf("failed to store transaction: %w", err)
+		}
+		return saveNextNonce(tx, from, next)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sendErr := tm.blockchainService.client.SendTransaction(ctx, signed)
+	switch {
+	case sendErr == nil || isAlreadyKnown(sendErr):
+	case isNonceTaken(sendErr):
+		// The nonce is gone, so next stays as it is
+		if err := tm.finish(ctx, &record, models.TxStatusFailed, sendErr.Error()); err != nil {
+			return &record, nil, err
+		}
+		return &record, nil, errNonceTaken
+	case !isRPCError(sendErr):
+		// The node may or may not have received it; Track finds out
+		tm.logger.WithError(sendErr).WithField("tx_hash", record.TxHash).Warn("Broadcast failed, transaction will be rebroadcast")
+	default:
+		if err := tm.finish(ctx, &record, models.TxStatusFailed, sendErr.Error()); err != nil {
+			return &record, nil, err
+		}
+		if next == nonce+1 {
+			if err := saveNextNonce(tm.db.WithContext(ctx), from, nonce); err != nil {
+				return &record, nil, err
+			}
+		}
+		return &record, nil, fmt.Errorf("failed to send transaction: %w", sendErr)
+	}
+
+	tm.logger.WithFields(logrus.Fields{
+		"tx_hash": record.TxHash,
+		"nonce":   nonce,
+		"method":  req.Method,
+	}).Info("Transaction sent")
+	return &record, signed, nil
+}
+
+// assignNonce returns the nonce for the next transaction and the stored next
+// nonce after it. When the node's pending nonce is behind the stored one, our
+// pending transactions in between are rebroadcast and the first nonce without
+// one is reused, so later transactions are not stuck behind the gap.
+func (tm *TxManager) assignNonce(ctx context.Context, from common.Address) (uint64, uint64, error) {
+	var stored models.SignerNonce
+	err := tm.db.WithContext(ctx).Where("address = ?", from.Hex()).First(&stored).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, 0, fmt.Errorf("failed to load signer nonce: %w", err)
+	}
+
+	chainNonce, err := tm.blockchainService.client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get pending nonce: %w", err)
+	}
+	if chainNonce >= stored.NextNonce {
+		// A fresh database, or transactions sent with the key from elsewhere
+		return chainNonce, chainNonce + 1, nil
+	}
+
+	var pending []models.Transaction
+	err = tm.db.WithContext(ctx).
+		Where("from_address = ? AND status = ? AND nonce >= ?", from.Hex(), models.TxStatusPending, chainNonce).
+		Order("nonce, id").
+		Find(&pending).Error
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load pending transactions: %w", err)
+	}
+
+	expected := chainNonce
+	for i := range pending {
+		if pending[i].Nonce > expected {
+			break
+		}
+		if pending[i].Nonce == expected {
+			if err := tm.rebroadcast(ctx, &pending[i]); err != nil {
+				tm.logger.WithError(err).WithField("tx_hash", pending[i].TxHash).Warn("Failed to rebroadcast pending transaction")
+			}
+			expected++
+		}
+	}
+	if expected < stored.NextNonce {
+		tm.logger.WithFields(logrus.Fields{
+			"nonce":      expected,
+			"next_nonce": stored.NextNonce,
+		}).Warn("Filling nonce gap")
+		return expected, stored.NextNonce, nil
+	}
+	return stored.NextNonce, stored.NextNonce + 1, nil
+}
+
+// estimate estimates the gas of req with current fees and applies the safety
+// multiplier, the block gas limit and the cost ceiling
+func (tm *TxManager) estimate(ctx context.Context, from common.Address, req TxRequest) (*GasEstimate, txFees, *types.Header, error) {
+	fees, head, err := tm.suggestFees(ctx)
+	if err != nil {
+		return nil, txFees{}, nil, err
+	}
+
+	msg := ethereum.CallMsg{From: from, To: &req.To, Data: req.Data}
+	if fees.Dynamic {
+		msg.GasFeeCap, msg.GasTipCap = fees.FeeCap, fees.TipCap
+	} else {
+		msg.GasPrice = fees.FeeCap
+	}
+	gas, err := tm.blockchainService.client.EstimateGas(ctx, msg)
+	if err != nil {
+		if isExecutionReverted(err) {
+			if reason := tm.blockchainService.revertReason(err); reason != "" {
+				return nil, txFees{}, nil, fmt.Errorf("%w: %s: %s", ErrExecutionReverted, req.Method, reason)
+			}
+			return nil, txFees{}, nil, fmt.Errorf("%w: %s: %v", ErrExecutionReverted, req.Method, err)
+		}
+		// Nodes stop estimating at the block gas limit
+		if strings.Contains(err.Error(), "gas required exceeds allowance") {
+			return nil, txFees{}, nil, fmt.Errorf("%w: %s: %v", ErrGasLimitExceeded, req.Method, err)
+		}
+		return nil, txFees{}, nil, fmt.Errorf("failed to estimate gas: %w", err)
+	}
+
+	gasLimit := min(uint64(float64(gas)*tm.config.GasMultiplier), head.GasLimit)
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(gasLimit), fees.FeeCap)
+	estimate := &GasEstimate{
+		Method:               req.Method,
+		To:                   req.To.Hex(),
+		EstimatedGas:         gas,
+		GasLimit:             gasLimit,
+		BlockGasLimit:        head.GasLimit,
+		MaxFeePerGas:         fees.FeeCap.String(),
+		MaxPriorityFeePerGas: fees.TipCap.String(),
+		MaxCost:              cost.String(),
+	}
+	if tm.config.MaxTxCost != nil {
+		estimate.MaxCostCeiling = tm.config.MaxTxCost.String()
+	}
+
+	if gas > head.GasLimit {
+		return estimate, fees, head, fmt.Errorf("%w: %s needs %d gas, the block gas limit is %d", ErrGasLimitExceeded, req.Method, gas, head.GasLimit)
+	}
+	if tm.config.MaxTxCost != nil && cost.Cmp(tm.config.MaxTxCost) > 0 {
+		return estimate, fees, head, fmt.Errorf("%w: %s may cost %s wei, the ceiling is %s", ErrTxCostExceeded, req.Method, cost, tm.config.MaxTxCost)
+	}
+	return estimate, fees, head, nil
+}
+
+// suggestFees returns EIP-1559 fees from the suggested priority fee and the
+// latest base fee, or a gas price on chains without a base fee, within the
+// configured caps
+func (tm *TxManager) suggestFees(ctx context.Context) (txFees, *types.Header, error) {
+	client := tm.blockchainService.client
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return txFees{}, nil, fmt.Errorf("failed to get latest header: %w", err)
+	}
+
+	if head.BaseFee == nil {
+		gasPrice, err := client.SuggestGasPrice(ctx)
+		if err != nil {
+			return txFees{}, nil, fmt.Errorf("failed to get gas price: %w", err)
+		}
+		if tm.config.MaxFeeCap != nil && gasPrice.Cmp(tm.config.MaxFeeCap) > 0 {
+			return txFees{}, nil, fmt.Errorf("%w: gas price is %s wei", ErrFeeCapExceeded, gasPrice)
+		}
+		return txFees{TipCap: gasPrice, FeeCap: gasPrice}, head, nil
+	}
+
+	tipCap, err := client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return txFees{}, nil, fmt.Errorf("failed to get priority fee: %w", err)
+	}
+	if tm.config.MaxTipCap != nil && tipCap.Cmp(tm.config.MaxTipCap) > 0 {
+		tipCap = new(big.Int).Set(tm.config.MaxTipCap)
+	}
+
+	// Twice the base fee keeps the transaction includable through several
+	// full blocks; only the base fee actually paid is burnt
+	feeCap := new(big.Int).Add(new(big.Int).Mul(head.BaseFee, big.NewInt(2)), tipCap)
+	if tm.config.MaxFeeCap != nil && feeCap.Cmp(tm.config.MaxFeeCap) > 0 {
+		if head.BaseFee.Cmp(tm.config.MaxFeeCap) >= 0 {
+			return txFees{}, nil, fmt.Errorf("%w: base fee is %s wei", ErrFeeCapExceeded, head.BaseFee)
+		}
+		feeCap = new(big.Int).Set(tm.config.MaxFeeCap)
+		if tipCap.Cmp(feeCap) > 0 {
+			tipCap = new(big.Int).Set(feeCap)
+		}
+	}
+	return txFees{TipCap: tipCap, FeeCap: feeCap, Dynamic: true}, head, nil
+}
+
+// bumpedFees raises fees by BumpPercent, and the fee cap to at least twice
+// the current base fee plus the tip. It returns false when the caps leave no
+// room for a raise the node would accept as a replacement.
+func (tm *TxManager) bumpedFees(current txFees, head *types.Header) (txFees, bool) {
+	raise := func(value *big.Int) *big.Int {
+		raised := new(big.Int).Mul(value, big.NewInt(100+tm.config.BumpPercent))
+		return raised.Div(raised, big.NewInt(100))
+	}
+	minTip, minFee := raise(current.TipCap), raise(current.FeeCap)
+	if minTip.Cmp(current.TipCap) == 0 {
+		minTip.Add(minTip, big.NewInt(1))
+	}
+	if minFee.Cmp(current.FeeCap) == 0 {
+		minFee.Add(minFee, big.NewInt(1))
+	}
+
+	bumped := txFees{TipCap: minTip, FeeCap: new(big.Int).Set(minFee), Dynamic: head.BaseFee != nil}
+	if !bumped.Dynamic {
+		bumped.FeeCap = bumped.TipCap
+		minFee = minTip
+	} else if fresh := new(big.Int).Add(new(big.Int).Mul(head.BaseFee, big.NewInt(2)), bumped.TipCap); fresh.Cmp(bumped.FeeCap) > 0 {
+		bumped.FeeCap = fresh
+	}
+
+	if tm.config.MaxTipCap != nil && bumped.Dynamic && bumped.TipCap.Cmp(tm.config.MaxTipCap) > 0 {
+		bumped.TipCap = new(big.Int).Set(tm.config.MaxTipCap)
+	}
+	if tm.config.MaxFeeCap != nil && bumped.FeeCap.Cmp(tm.config.MaxFeeCap) > 0 {
+		bumped.FeeCap = new(big.Int).Set(tm.config.MaxFeeCap)
+		if !bumped.Dynamic {
+			bumped.TipCap = bumped.FeeCap
+		}
+	}
+	if bumped.TipCap.Cmp(minTip) < 0 || bumped.FeeCap.Cmp(minFee) < 0 {
+		return current, false
+	}
+	return bumped, true
+}
+
+// sign signs the transaction described by record, data and fees and stores
+// its hash, fees and encoding in record
+func (tm *TxManager) sign(ctx context.Context, record *models.Transaction, data []byte, fees txFees, head *types.Header) (*types.Transaction, error) {
+	chainID, err := tm.blockchainService.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	to := common.HexToAddress(record.ToAddress)
+	var tx *types.Transaction
+	if fees.Dynamic {
+		tx = types.NewTx(&types.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     record.Nonce,
+			GasTipCap: fees.TipCap,
+			GasFeeCap: fees.FeeCap,
+			Gas:       record.GasLimit,
+			To:        &to,
+			Value:     big.NewInt(0),
+			Data:      data,
+		})
+	} else {
+		tx = types.NewTx(&types.LegacyTx{
+			Nonce:    record.Nonce,
+			GasPrice: fees.FeeCap,
+			Gas:      record.GasLimit,
+			To:       &to,
+			Value:    big.NewInt(0),
+			Data:     data,
+		})
+	}
+
+	signed, err := types.SignTx(tx, types.LatestSignerForChainID(chainID), tm.blockchainService.privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	raw, err := signed.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode transaction: %w", err)
+	}
+
+	record.TxHash = signed.Hash().Hex()
+	record.RawTx = hexutil.Encode(raw)
+	record.GasTipCap = fees.TipCap.String()
+	record.GasFeeCap = fees.FeeCap.String()
+	record.SentBlock = head.Number.Uint64()
+	return signed, nil
+}
+
+// bump replaces a stuck transaction with a copy with higher fees and the
+// same nonce. Either version may end up mined, so the replaced hash is kept.
+func (tm *TxManager) bump(ctx context.Context, record *models.Transaction, head *types.Header) error {
+	current, err := decodeRawTx(record.RawTx)
+	if err != nil {
+		return err
+	}
+	fees, ok := tm.bumpedFees(txFees{TipCap: current.GasTipCap(), FeeCap: current.GasFeeCap(), Dynamic: current.Type() == types.DynamicFeeTxType}, head)
+	if !ok {
+		tm.logger.WithFields(logrus.Fields{
+			"tx_hash":     record.TxHash,
+			"gas_fee_cap": record.GasFeeCap,
+		}).Warn("Stuck transaction is at the configured fee caps and cannot be replaced")
+		return nil
+	}
+
+	replacement := *record
+	signed, err := tm.sign(ctx, &replacement, current.Data(), fees, head)
+	if err != nil {
+		return err
+	}
+	if err := tm.blockchainService.client.SendTransaction(ctx, signed); err != nil && !isAlreadyKnown(err) {
+		// A nonce error means the current version was just mined
+		return fmt.Errorf("failed to send replacement transaction: %w", err)
+	}
+
+	replacement.ReplacedHashes = joinHashes(record.ReplacedHashes, record.TxHash)
+	replacement.Attempts++
+	err = tm.db.WithContext(ctx).Model(record).Updates(map[string]interface{}{
+		"tx_hash":         replacement.TxHash,
+		"replaced_hashes": replacement.ReplacedHashes,
+		"raw_tx":          replacement.RawTx,
+		"gas_tip_cap":     replacement.GasTipCap,
+		"gas_fee_cap":     replacement.GasFeeCap,
+		"sent_block":      replacement.SentBlock,
+		"attempts":        replacement.Attempts,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update transaction: %w", err)
+	}
+
+	tm.logger.WithFields(logrus.Fields{
+		"tx_hash":     replacement.TxHash,
+		"replaced":    record.TxHash,
+		"nonce":       record.Nonce,
+		"gas_fee_cap": replacement.GasFeeCap,
+		"gas_tip_cap": replacement.GasTipCap,
+	}).Info("Stuck transaction replaced with higher fees")
+	*record = replacement
+	return nil
+}
+
+// receipt returns the receipt of whichever version of the transaction was
+// mined, and makes that version the record's hash
+func (tm *TxManager) receipt(ctx context.Context, record *models.Transaction) (*types.Receipt, error) {
+	hashes := []string{record.TxHash}
+	if record.ReplacedHashes != "" {
+		hashes = append(hashes, strings.Split(record.ReplacedHashes, ",")...)
+	}
+	for _, hash := range hashes {
+		receipt, err := tm.blockchainService.TransactionReceipt(ctx, common.HexToHash(hash))
+		if err != nil {
+			return nil, err
+		}
+		if receipt != nil {
+			record.TxHash = hash
+			return receipt, nil
+		}
+	}
+	return nil, nil
+}
+
+// rebroadcastIfMissing resends a pending transaction the node does not know
+func (tm *TxManager) rebroadcastIfMissing(ctx context.Context, record *models.Transaction) error {
+	_, _, err := tm.blockchainService.client.TransactionByHash(ctx, common.HexToHash(record.TxHash))
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		return fmt.Errorf("failed to get transaction: %w", err)
+	}
+	return tm.rebroadcast(ctx, record)
+}
+
+// rebroadcast resends the stored signed transaction unchanged
+func (tm *TxManager) rebroadcast(ctx context.Context, record *models.Transaction) error {
+	signed, err := decodeRawTx(record.RawTx)
+	if err != nil {
+		return err
+	}
+
+	if err := tm.blockchainService.client.SendTransaction(ctx, signed); err != nil && !isAlreadyKnown(err) {
+		return fmt.Errorf("failed to rebroadcast transaction: %w", err)
+	}
+	record.Attempts++
+	if err := tm.db.WithContext(ctx).Model(record).Update("attempts", record.Attempts).Error; err != nil {
+		return fmt.Errorf("failed to update transaction: %w", err)
+	}
+	tm.logger.WithFields(logrus.Fields{
+		"tx_hash": record.TxHash,
+		"nonce":   record.Nonce,
+	}).Info("Transaction rebroadcast")
+	return nil
+}
+
+// recordReceipt stores the block and gas usage of a mined transaction. It
+// stays mined until Confirmations blocks are on top of it, as of head; the
+// receipt is fetched again on every check, so a transaction is only final if
+// its receipt outlived any reorg in between.
+func (tm *TxManager) recordReceipt(ctx context.Context, record *models.Transaction, receipt *types.Receipt, head uint64) error {
+	blockNumber := receipt.BlockNumber.Uint64()
+	final := head >= blockNumber+tm.config.Confirmations
+	if !final && record.Status == models.TxStatusMined && record.BlockHash == receipt.BlockHash.Hex() {
+		return nil
+	}
+
+	from := record.Status
+	now := time.Now()
+	record.Status = models.TxStatusMined
+	record.Error = ""
+	record.RevertReason = ""
+	if final {
+		record.Status = models.TxStatusConfirmed
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			record.Status = models.TxStatusReverted
+			record.Error = "execution reverted"
+			reason, err := tm.blockchainService.ReplayRevert(ctx, record, receipt.BlockNumber)
+			if err != nil {
+				tm.logger.WithError(err).WithField("tx_hash", record.TxHash).Warn("Failed to decode revert reason")
+			}
+			if reason != "" {
+				record.RevertReason = reason
+				record.Error += ": " + reason
+			}
+		}
+	}
+	record.BlockNumber = blockNumber
+	record.BlockHash = receipt.BlockHash.Hex()
+	record.GasUsed = receipt.GasUsed
+	record.EffectiveGasPrice = "0"
+	if receipt.EffectiveGasPrice != nil {
+		record.EffectiveGasPrice = receipt.EffectiveGasPrice.String()
+	}
+	if from == models.TxStatusPending || record.MinedAt == nil {
+		record.MinedAt = &now
+	}
+
+	// The status condition keeps WaitMined and Track from both finalising it
+	result := tm.db.WithContext(ctx).Model(&models.Transaction{}).
+		Where("id = ? AND status = ?", record.ID, from).
+		Updates(map[string]interface{}{
+			"status":              record.Status,
+			"tx_hash":             record.TxHash,
+			"block_number":        record.BlockNumber,
+			"block_hash":          record.BlockHash,
+			"gas_used":            record.GasUsed,
+			"effective_gas_price": record.EffectiveGasPrice,
+			"error":               record.Error,
+			"revert_reason":       record.RevertReason,
+			"mined_at":            record.MinedAt,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update transaction: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return tm.db.WithContext(ctx).First(record, record.ID).Error
+	}
+
+	fields := logrus.Fields{
+		"tx_hash":      record.TxHash,
+		"method":       record.Method,
+		"block_number": record.BlockNumber,
+		"gas_used":     record.GasUsed,
+		"status":       record.Status,
+	}
+	if !final {
+		tm.logger.WithFields(fields).Info("Transaction mined")
+		return nil
+	}
+	if record.Status == models.TxStatusReverted {
+		tm.logRevert(ctx, record)
+	}
+	tm.logger.WithFields(fields).Info("Transaction final")
+	tm.complete(ctx, record)
+	return nil
+}
+
+// unmine puts a mined transaction whose receipt disappeared in a reorg back
+// to pending, so it is tracked, replaced or rebroadcast like any other
+func (tm *TxManager) unmine(ctx context.Context, record *models.Transaction) error {
+	tm.logger.WithFields(logrus.Fields{
+		"tx_hash":      record.TxHash,
+		"block_number": record.BlockNumber,
+	}).Warn("Mined transaction reorganised out of the chain")
+
+	record.Status = models.TxStatusPending
+	record.BlockNumber = 0
+	record.BlockHash = ""
+	record.MinedAt = nil
+	err := tm.db.WithContext(ctx).Model(&models.Transaction{}).
+		Where("id = ? AND status = ?", record.ID, models.TxStatusMined).
+		Updates(map[string]interface{}{
+			"status":       record.Status,
+			"block_number": record.BlockNumber,
+			"block_hash":   record.BlockHash,
+			"mined_at":     record.MinedAt,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update transaction: %w", err)
+	}
+	return nil
+}
+
+func (tm *TxManager) finish(ctx context.Context, record *models.Transaction, status, reason string) error {
+	record.Status = status
+	record.Error = reason
+	err := tm.db.WithContext(ctx).Model(record).Updates(map[string]interface{}{
+		"status": status,
+		"error":  reason,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update transaction: %w", err)
+	}
+	tm.complete(ctx, record)
+	return nil
+}
+
+// logRevert records a reverted transaction in the system log
+func (tm *TxManager) logRevert(ctx context.Context, record *models.Transaction) {
+	details, _ := json.Marshal(map[string]interface{}{
+		"tx_id":         record.ID,
+		"method":        record.Method,
+		"params":        json.RawMessage(record.Params),
+		"nonce":         record.Nonce,
+		"block_number":  record.BlockNumber,
+		"revert_reason": record.RevertReason,
+	})
+	err := tm.db.WithContext(ctx).Create(&models.SystemLog{
+		Level:     "error",
+		Component: "blockchain",
+		Message:   fmt.Sprintf("Transaction %s reverted", record.Method),
+		Details:   string(details),
+		TxHash:    record.TxHash,
+	}).Error
+	if err != nil {
+		tm.logger.WithError(err).WithField("tx_hash", record.TxHash).Error("Failed to log reverted transaction")
+	}
+}
+
+// complete passes a transaction that reached a final status to the
+// registered callbacks
+func (tm *TxManager) complete(ctx context.Context, record *models.Transaction) {
+	for _, fn := range tm.onComplete {
+		fn(ctx, record)
+	}
+}
+
+func (tm *TxManager) signer() (common.Address, error) {
+	if tm.blockchainService.privateKey == nil {
+		return common.Address{}, fmt.Errorf("private key not set")
+	}
+	return crypto.PubkeyToAddress(tm.blockchainService.privateKey.PublicKey), nil
+}
+
+func decodeRawTx(rawTx string) (*types.Transaction, error) {
+	raw, err := hexutil.Decode(rawTx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode stored transaction: %w", err)
+	}
+	var signed types.Transaction
+	if err := signed.UnmarshalBinary(raw); err != nil {
+		return nil, fmt.Errorf("failed to decode stored transaction: %w", err)
+	}
+	return &signed, nil
+}
+
+func joinHashes(hashes, hash string) string {
+	if hashes == "" {
+		return hash
+	}
+	return hashes + "," + hash
+}
+
+func saveNextNonce(tx *gorm.DB, from common.Address, next uint64) error {
+	record := models.SignerNonce{Address: from.Hex(), NextNonce: next}
+	if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&record).Error; err != nil {
+		return fmt.Errorf("failed to store signer nonce: %w", err)
+	}
+	return nil
+}
+
+// isAlreadyKnown reports whether the node already has the transaction
+func isAlreadyKnown(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "already known") || strings.Contains(message, "known transaction")
+}
+
+// isNonceTaken reports whether the node has used, or holds another
+// transaction for, the nonce
+func isNonceTaken(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "nonce too low") || strings.Contains(message, "replacement transaction underpriced")
+}
+
+// isRPCError reports whether err is an error response from the node rather
+// than a transport failure, after which the request may still have arrived
+func isRPCError(err error) bool {
+	var rpcErr rpc.Error
+	return errors.As(err, &rpcErr)
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// gwei returns tenths of a gwei in wei, so 25 is 2.5 gwei
+func gwei(tenths int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(tenths), big.NewInt(params.GWei/10))
+}
+
+func TestBumpedFees(t *testing.T) {
+	dynamic := func(tip, fee *big.Int) txFees { return txFees{TipCap: tip, FeeCap: fee, Dynamic: true} }
+	legacy := func(price *big.Int) txFees { return txFees{TipCap: price, FeeCap: price} }
+	withBaseFee := func(baseFee *big.Int) *types.Header { return &types.Header{BaseFee: baseFee} }
+
+	tests := []struct {
+		name    string
+		config  TxManagerConfig
+		current txFees
+		head    *types.Header
+		want    txFees
+		wantOK  bool
+	}{
+		{
+			name:    "dynamic fees raised by the bump percent",
+			current: dynamic(gwei(20), gwei(300)),
+			head:    withBaseFee(gwei(100)),
+			want:    dynamic(gwei(22), gwei(330)),
+			wantOK:  true,
+		},
+		{
+			name:    "fee cap follows a risen base fee",
+			current: dynamic(gwei(20), gwei(300)),
+			head:    withBaseFee(gwei(200)),
+			want:    dynamic(gwei(22), gwei(422)),
+			wantOK:  true,
+		},
+		{
+			name:    "custom bump percent",
+			config:  TxManagerConfig{BumpPercent: 25},
+			current: dynamic(gwei(20), gwei(300)),
+			head:    withBaseFee(gwei(100)),
+			want:    dynamic(gwei(25), gwei(375)),
+			wantOK:  true,
+		},
+		{
+			name:    "bump percent below the node minimum uses 10%",
+			config:  TxManagerConfig{BumpPercent: 5},
+			current: dynamic(gwei(20), gwei(300)),
+			head:    withBaseFee(gwei(100)),
+			want:    dynamic(gwei(22), gwei(330)),
+			wantOK:  true,
+		},
+		{
+			name:    "values too small to round up are raised by one wei",
+			current: dynamic(big.NewInt(1), big.NewInt(5)),
+			head:    withBaseFee(big.NewInt(1)),
+			want:    dynamic(big.NewInt(2), big.NewInt(6)),
+			wantOK:  true,
+		},
+		{
+			name:    "legacy gas price",
+			current: legacy(gwei(100)),
+			head:    withBaseFee(nil),
+			want:    legacy(gwei(110)),
+			wantOK:  true,
+		},
+		{
+			name:    "legacy gas price ignores the tip cap",
+			config:  TxManagerConfig{MaxTipCap: gwei(10)},
+			current: legacy(gwei(100)),
+			head:    withBaseFee(nil),
+			want:    legacy(gwei(110)),
+			wantOK:  true,
+		},
+		{
+			name:    "fee cap clamped to the configured maximum",
+			config:  TxManagerConfig{MaxFeeCap: gwei(400)},
+			current: dynamic(gwei(20), gwei(300)),
+			head:    withBaseFee(gwei(200)),
+			want:    dynamic(gwei(22), gwei(400)),
+			wantOK:  true,
+		},
+		{
+			name:    "fee cap maximum below the minimum replacement",
+			config:  TxManagerConfig{MaxFeeCap: gwei(320)},
+			current: dynamic(gwei(20), gwei(300)),
+			head:    withBaseFee(gwei(100)),
+			want:    dynamic(gwei(20), gwei(300)),
+			wantOK:  false,
+		},
+		{
+			name:    "tip cap maximum below the minimum replacement",
+			config:  TxManagerConfig{MaxTipCap: gwei(20)},
+			current: dynamic(gwei(20), gwei(300)),
+			head:    withBaseFee(gwei(100)),
+			want:    dynamic(gwei(20), gwei(300)),
+			wantOK:  false,
+		},
+		{
+			name:    "legacy gas price maximum below the minimum replacement",
+			config:  TxManagerConfig{MaxFeeCap: gwei(105)},
+			current: legacy(gwei(100)),
+			head:    withBaseFee(nil),
+			want:    legacy(gwei(100)),
+			wantOK:  false,
+		},
+		{
+			name:    "legacy gas price at the configured maximum",
+			config:  TxManagerConfig{MaxFeeCap: gwei(100)},
+			current: legacy(gwei(100)),
+			head:    withBaseFee(nil),
+			want:    legacy(gwei(100)),
+			wantOK:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm := NewTxManager(nil, nil, nil, tt.config, nil)
+			got, ok := tm.bumpedFees(tt.current, tt.head)
+			if ok != tt.wantOK {
+				t.Fatalf("bumpedFees() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got.Dynamic != tt.want.Dynamic || got.TipCap.Cmp(tt.want.TipCap) != 0 || got.FeeCap.Cmp(tt.want.FeeCap) != 0 {
+				t.Fatalf("bumpedFees() = {tip %v, fee %v, dynamic %v}, want {tip %v, fee %v, dynamic %v}",
+					got.TipCap, got.FeeCap, got.Dynamic, tt.want.TipCap, tt.want.FeeCap, tt.want.Dynamic)
+			}
+		})
+	}
+}
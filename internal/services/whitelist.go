@@ -1,19 +1,49 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"whitelist-token-backend/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
+// Whitelist errors returned to handlers
+var (
+	ErrWhitelistEntryNotFound = errors.New("whitelist entry not found")
+	ErrNotWhitelisted         = errors.New("address is not whitelisted")
+	ErrInvalidAllocation      = errors.New("invalid allocation")
+)
+
+// receiptTimeout bounds the bookkeeping done after a chain call, which must
+// still run when the request context has already expired
+const receiptTimeout = 10 * time.Second
+
 // WhitelistService handles whitelist operations
 type WhitelistService struct {
 	db               *gorm.DB
 	redis            *redis.Client
 	blockchainService *BlockchainService
+	mode             string
 	logger           *logrus.Logger
 }
 
+// WhitelistFilter narrows the entries returned by List
+type WhitelistFilter struct {
+	Status      string
+	Whitelisted *bool
+	Page        int
+	PageSize    int
+}
+
 // NewWhitelistService creates a new whitelist service
 func NewWhitelistService(
 	db *gorm.DB,
@@ -25,6 +55,466 @@ func NewWhitelistService(
 		db:               db,
 		redis:            redis,
 		blockchainService: blockchainService,
+		mode:             WhitelistModeMapping,
 		logger:           logger,
 	}
-}
\ No newline at end of file
+}
+
+// SetMode selects whether whitelist changes go to the token contract's
+// mapping or only to the database for inclusion in the next merkle root
+func (ws *WhitelistService) SetMode(mode string) error {
+	switch mode {
+	case WhitelistModeMapping, WhitelistModeMerkle:
+		ws.mode = mode
+		return nil
+	default:
+		return fmt.Errorf("unknown whitelist mode %q", mode)
+	}
+}
+
+// Mode returns the configured whitelist mode
+func (ws *WhitelistService) Mode() string {
+	return ws.mode
+}
+
+// IsWhitelisted checks an address against the token contract's mapping, or
+// for a leaf in the active merkle root in merkle mode
+func (ws *WhitelistService) IsWhitelisted(ctx context.Context, address string) (bool, error) {
+	if ws.mode != WhitelistModeMerkle {
+		return ws.blockchainService.IsWhitelisted(ctx, address, nil)
+	}
+
+	address, err := NormalizeAddress(address)
+	if err != nil {
+		return false, err
+	}
+	root, err := ws.activeMerkleRoot(ctx)
+	if err != nil {
+		if errors.Is(err, ErrNoMerkleRoot) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	var count int64
+	err = ws.db.WithContext(ctx).Model(&models.MerkleLeaf{}).
+		Where("root_id = ? AND address = ?", root.ID, address).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to load merkle leaf: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Add whitelists an address on-chain and records who added it. The entry is
+// stored as pending before the transaction is sent, so a failed or timed-out
+// transaction still leaves a trace of the attempt.
+func (ws *WhitelistService) Add(ctx context.Context, address, maxAllocation, addedBy string) (*models.WhitelistEntry, error) {
+	address, err := NormalizeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	allocation, err := parseAllocation(maxAllocation)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry models.WhitelistEntry
+	err = ws.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := findOrCreateUser(tx, address)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Unscoped().Where("address = ?", address).First(&entry).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load whitelist entry: %w", err)
+		}
+
+		entry.UserID = user.ID
+		entry.Address = address
+		entry.WasWhitelisted = entry.IsWhitelisted && !entry.DeletedAt.Valid
+		entry.IsWhitelisted = true
+		entry.MaxAllocation = allocation
+		entry.Status = ws.initialStatus()
+		entry.TxHash = ""
+		entry.TxID = 0
+		entry.BlockNumber = 0
+		entry.AddedBy = addedBy
+		entry.AddedAt = time.Now()
+		entry.RemovedBy = ""
+		entry.RemovedAt = nil
+		entry.DeletedAt = gorm.DeletedAt{}
+		if entry.UsedAllocation == "" {
+			entry.UsedAllocation = "0"
+		}
+
+		if err := tx.Unscoped().Save(&entry).Error; err != nil {
+			return fmt.Errorf("failed to save whitelist entry: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// In merkle mode the address takes effect with the next generated root
+	if ws.mode != WhitelistModeMerkle {
+		chainTx, chainErr := ws.blockchainService.AddToWhitelist(ctx, []string{address})
+		if err := ws.recordOutcome(ctx, &entry, chainTx, chainErr); err != nil {
+			return &entry, err
+		}
+	}
+
+	ws.logger.WithFields(logrus.Fields{
+		"address":  address,
+		"added_by": addedBy,
+		"tx_hash":  entry.TxHash,
+		"status":   entry.Status,
+	}).Info("Address added to whitelist")
+	return &entry, nil
+}
+
+// Remove removes an address from the on-chain whitelist. The entry is kept
+// with IsWhitelisted=false so the history of who added and removed it remains.
+func (ws *WhitelistService) Remove(ctx context.Context, address, removedBy string) (*models.WhitelistEntry, error) {
+	address, err := NormalizeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry models.WhitelistEntry
+	if err := ws.db.WithContext(ctx).Where("address = ?", address).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotWhitelisted
+		}
+		return nil, fmt.Errorf("failed to load whitelist entry: %w", err)
+	}
+	if !entry.IsWhitelisted {
+		return nil, ErrNotWhitelisted
+	}
+
+	now := time.Now()
+	entry.WasWhitelisted = true
+	entry.IsWhitelisted = false
+	entry.Status = ws.initialStatus()
+	entry.TxHash = ""
+	entry.TxID = 0
+	entry.BlockNumber = 0
+	entry.RemovedBy = removedBy
+	entry.RemovedAt = &now
+	if err := ws.db.WithContext(ctx).Save(&entry).Error; err != nil {
+		return nil, fmt.Errorf("failed to save whitelist entry: %w", err)
+	}
+
+	if ws.mode != WhitelistModeMerkle {
+		chainTx, chainErr := ws.blockchainService.RemoveFromWhitelist(ctx, []string{address})
+		if err := ws.recordOutcome(ctx, &entry, chainTx, chainErr); err != nil {
+			return &entry, err
+		}
+	}
+
+	ws.logger.WithFields(logrus.Fields{
+		"address":    address,
+		"removed_by": removedBy,
+		"tx_hash":    entry.TxHash,
+		"status":     entry.Status,
+	}).Info("Address removed from whitelist")
+	return &entry, nil
+}
+
+// Get returns the whitelist entry for an address. Pending entries are
+// re-checked against their transaction receipt first.
+func (ws *WhitelistService) Get(ctx context.Context, address string) (*models.WhitelistEntry, error) {
+	address, err := NormalizeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry models.WhitelistEntry
+	if err := ws.db.WithContext(ctx).Where("address = ?", address).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWhitelistEntryNotFound
+		}
+		return nil, fmt.Errorf("failed to load whitelist entry: %w", err)
+	}
+
+	if entry.Status == models.WhitelistStatusPending && entry.TxHash != "" {
+		if err := ws.refreshStatus(ctx, &entry); err != nil {
+			ws.logger.WithError(err).WithField("address", address).Warn("Failed to refresh whitelist entry status")
+		}
+	}
+
+	return &entry, nil
+}
+
+// List returns a page of whitelist entries, most recently added first
+func (ws *WhitelistService) List(ctx context.Context, filter WhitelistFilter) ([]models.WhitelistEntry, int64, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 || filter.PageSize > 500 {
+		filter.PageSize = 50
+	}
+
+	query := ws.db.WithContext(ctx).Model(&models.WhitelistEntry{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Whitelisted != nil {
+		query = query.Where("is_whitelisted = ?", *filter.Whitelisted)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count whitelist entries: %w", err)
+	}
+
+	var entries []models.WhitelistEntry
+	err := query.Order("added_at DESC").
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&entries).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list whitelist entries: %w", err)
+	}
+
+	return entries, total, nil
+}
+
+// WhitelistChange is a single add or remove applied by a batch
+type WhitelistChange struct {
+	Address       string `json:"address"`
+	MaxAllocation string `json:"max_allocation,omitempty"`
+	Tier          string `json:"tier,omitempty"`
+}
+
+// RecordBatchChange stores the outcome of a batch transaction on the entries
+// it touched. Failed changes only update the status of existing entries.
+func (ws *WhitelistService) RecordBatchChange(ctx context.Context, changes []WhitelistChange, add bool, actor, status, txHash string, blockNumber uint64) error {
+	now := time.Now()
+	return ws.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, change := range changes {
+			var entry models.WhitelistEntry
+			err := tx.Unscoped().Where("address = ?", change.Address).First(&entry).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("failed to load whitelist entry: %w", err)
+			}
+
+			if status == models.WhitelistStatusFailed {
+				if entry.ID == 0 {
+					continue
+				}
+				if err := tx.Model(&entry).Updates(map[string]interface{}{"status": status, "tx_hash": txHash, "tx_id": 0}).Error; err != nil {
+					return fmt.Errorf("failed to update whitelist entry: %w", err)
+				}
+				continue
+			}
+
+			if entry.ID == 0 {
+				if !add {
+					continue
+				}
+				user, err := findOrCreateUser(tx, change.Address)
+				if err != nil {
+					return err
+				}
+				entry.UserID = user.ID
+				entry.Address = change.Address
+				entry.UsedAllocation = "0"
+			}
+
+			if add {
+				entry.IsWhitelisted = true
+				entry.AddedBy = actor
+				entry.AddedAt = now
+				entry.RemovedBy = ""
+				entry.RemovedAt = nil
+				entry.DeletedAt = gorm.DeletedAt{}
+				if change.MaxAllocation != "" {
+					entry.MaxAllocation = change.MaxAllocation
+				}
+				if entry.MaxAllocation == "" {
+					entry.MaxAllocation = "0"
+				}
+				if change.Tier != "" {
+					entry.Tier = change.Tier
+				}
+			} else {
+				entry.IsWhitelisted = false
+				entry.RemovedBy = actor
+				entry.RemovedAt = &now
+			}
+			entry.Status = status
+			entry.TxHash = txHash
+			entry.TxID = 0
+			entry.BlockNumber = blockNumber
+
+			if err := tx.Unscoped().Save(&entry).Error; err != nil {
+				return fmt.Errorf("failed to save whitelist entry: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateAllocation changes the allocation and tier of an existing entry. The
+// on-chain mapping does not hold allocations, so no transaction is needed.
+func (ws *WhitelistService) UpdateAllocation(ctx context.Context, address, maxAllocation, tier string) error {
+	allocation, err := parseAllocation(maxAllocation)
+	if err != nil {
+		return err
+	}
+
+	result := ws.db.WithContext(ctx).Model(&models.WhitelistEntry{}).
+		Where("address = ?", address).
+		Updates(map[string]interface{}{"max_allocation": allocation, "tier": tier})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update allocation: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrWhitelistEntryNotFound
+	}
+	return nil
+}
+
+// initialStatus is the status of an entry before its change reaches the chain.
+// Merkle mode sends no transaction, so changes are confirmed immediately.
+func (ws *WhitelistService) initialStatus() string {
+	if ws.mode == WhitelistModeMerkle {
+		return models.WhitelistStatusConfirmed
+	}
+	return models.WhitelistStatusPending
+}
+
+// recordOutcome links the entry to the transaction sent for it. The entry
+// stays pending until TransactionComplete applies the transaction's outcome.
+// When nothing was broadcast the change is undone and chainErr returned
+// (wrapped).
+func (ws *WhitelistService) recordOutcome(ctx context.Context, entry *models.WhitelistEntry, record *models.Transaction, chainErr error) error {
+	bgCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), receiptTimeout)
+	defer cancel()
+
+	if chainErr != nil {
+		entry.Status = models.WhitelistStatusFailed
+		entry.IsWhitelisted = entry.WasWhitelisted
+		err := ws.db.WithContext(bgCtx).Model(entry).Updates(map[string]interface{}{
+			"status":         entry.Status,
+			"is_whitelisted": entry.IsWhitelisted,
+		}).Error
+		if err != nil {
+			ws.logger.WithError(err).WithField("address", entry.Address).Error("Failed to mark whitelist entry failed")
+		}
+		return fmt.Errorf("failed to submit whitelist transaction: %w", chainErr)
+	}
+
+	entry.TxID = record.ID
+	entry.TxHash = record.TxHash
+	err := ws.db.WithContext(bgCtx).Model(entry).Updates(map[string]interface{}{
+		"tx_id":   entry.TxID,
+		"tx_hash": entry.TxHash,
+	}).Error
+	if err != nil {
+		ws.logger.WithError(err).WithField("address", entry.Address).Error("Failed to store whitelist transaction")
+	}
+	return nil
+}
+
+// TransactionComplete applies the outcome of a mined, failed or dropped
+// transaction to the entries it was sent for. It is registered with the
+// transaction manager.
+func (ws *WhitelistService) TransactionComplete(ctx context.Context, record *models.Transaction) {
+	var entries []models.WhitelistEntry
+	if err := ws.db.WithContext(ctx).Where("tx_id = ? AND status = ?", record.ID, models.WhitelistStatusPending).Find(&entries).Error; err != nil {
+		ws.logger.WithError(err).WithField("tx_id", record.ID).Error("Failed to load whitelist entries for transaction")
+		return
+	}
+	for i := range entries {
+		if err := ws.applyTransaction(ctx, &entries[i], record); err != nil {
+			ws.logger.WithError(err).WithField("address", entries[i].Address).Error("Failed to update whitelist entry")
+		}
+	}
+}
+
+// refreshStatus updates a pending entry from its transaction record, or from
+// the receipt for entries written before transactions were recorded. Entries
+// whose transaction is not mined yet stay pending.
+func (ws *WhitelistService) refreshStatus(ctx context.Context, entry *models.WhitelistEntry) error {
+	if entry.TxID != 0 {
+		record, err := ws.blockchainService.GetTransaction(ctx, entry.TxID)
+		if err != nil {
+			return err
+		}
+		return ws.applyTransaction(ctx, entry, record)
+	}
+
+	receipt, err := ws.blockchainService.TransactionReceipt(ctx, common.HexToHash(entry.TxHash))
+	if err != nil {
+		return err
+	}
+
+	if receipt != nil {
+		entry.BlockNumber = receipt.BlockNumber.Uint64()
+		if receipt.Status == types.ReceiptStatusSuccessful {
+			entry.Status = models.WhitelistStatusConfirmed
+		} else {
+			// These entries predate WasWhitelisted; the reverted change is undone
+			entry.Status = models.WhitelistStatusFailed
+			entry.IsWhitelisted = !entry.IsWhitelisted
+		}
+	}
+
+	err = ws.db.WithContext(ctx).Model(entry).Updates(map[string]interface{}{
+		"tx_hash":        entry.TxHash,
+		"block_number":   entry.BlockNumber,
+		"status":         entry.Status,
+		"is_whitelisted": entry.IsWhitelisted,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update whitelist entry: %w", err)
+	}
+	return nil
+}
+
+// applyTransaction sets the entry's status from its transaction once the
+// transaction has a final status. A change whose transaction failed, reverted
+// or was dropped is undone.
+func (ws *WhitelistService) applyTransaction(ctx context.Context, entry *models.WhitelistEntry, record *models.Transaction) error {
+	switch record.Status {
+	case models.TxStatusPending, models.TxStatusMined:
+		return nil
+	case models.TxStatusConfirmed:
+		entry.Status = models.WhitelistStatusConfirmed
+	default:
+		entry.Status = models.WhitelistStatusFailed
+		entry.IsWhitelisted = entry.WasWhitelisted
+	}
+	entry.TxHash = record.TxHash
+	entry.BlockNumber = record.BlockNumber
+
+	// The entry may have been changed again since the transaction was sent
+	err := ws.db.WithContext(ctx).Model(&models.WhitelistEntry{}).
+		Where("id = ? AND tx_id = ?", entry.ID, record.ID).
+		Updates(map[string]interface{}{
+			"tx_hash":        entry.TxHash,
+			"block_number":   entry.BlockNumber,
+			"status":         entry.Status,
+			"is_whitelisted": entry.IsWhitelisted,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update whitelist entry: %w", err)
+	}
+	return nil
+}
+
+// parseAllocation validates a token allocation in base units; empty means no cap
+func parseAllocation(s string) (string, error) {
+	if s == "" {
+		return "0", nil
+	}
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok || v.Sign() < 0 {
+		return "", fmt.Errorf("%w: %q", ErrInvalidAllocation, s)
+	}
+	return v.String(), nil
+}